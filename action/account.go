@@ -0,0 +1,436 @@
+package action
+
+import (
+	"errors"
+	"example.com/micro/client/login"
+	"example.com/micro/config"
+	"example.com/micro/model"
+	"example.com/micro/model/core/obj"
+	"example.com/micro/secret"
+	"example.com/micro/totp"
+	"fmt"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+)
+
+const accountPageSize = 1000
+
+func accountNotFound() *common.APIResponse {
+	return &common.APIResponse{
+		Status:    common.APIStatus.NotFound,
+		Message:   "Account not found",
+		ErrorCode: "ACCOUNT_NOT_FOUND",
+	}
+}
+
+func encryptionFailed(err error) *common.APIResponse {
+	return &common.APIResponse{
+		Status:    common.APIStatus.Error,
+		Message:   "Cannot encrypt password: " + err.Error(),
+		ErrorCode: "ENCRYPTION_FAILED",
+	}
+}
+
+func hidePasswords(resp *common.APIResponse) *common.APIResponse {
+	if accounts, ok := resp.Data.([]*model.Account); ok {
+		for _, account := range accounts {
+			account.Password = ""
+			account.TOTPSeed = ""
+		}
+	}
+	return resp
+}
+
+// FieldError is an invalid field of an account, named as in its JSON.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+var errRequired = errors.New("is required")
+
+func validateAccount(input *model.Account, partial bool) error {
+	if problems := accountProblems(input, partial); len(problems) > 0 {
+		return problems[0]
+	}
+	return nil
+}
+
+// accountProblems lists every invalid field of input. A partial input only
+// holds the fields to change.
+func accountProblems(input *model.Account, partial bool) []*FieldError {
+	var problems []*FieldError
+	add := func(field string, err error) {
+		problems = append(problems, &FieldError{Field: field, Err: err})
+	}
+
+	if !partial {
+		for _, required := range []struct{ field, value string }{
+			{"username", input.Username},
+			{"password", input.Password},
+			{"type", input.Type},
+			{"domainType", input.DomainType},
+		} {
+			if required.value == "" {
+				add(required.field, errRequired)
+			}
+		}
+	}
+	if input.Type != "" && !config.IsAccountType(input.Type) {
+		add("type", fmt.Errorf("unknown account type %q (valid: %s)", input.Type, strings.Join(config.AccountTypes, ", ")))
+	}
+	if input.DomainType != "" && !login.IsSupported(input.DomainType) {
+		add("domainType", fmt.Errorf("unknown domainType %q (valid: %s)", input.DomainType, strings.Join(config.EnvironmentNames(), ", ")))
+	}
+	if input.Status != "" && input.Status != model.AccountStatus.Active &&
+		input.Status != model.AccountStatus.Disabled && input.Status != model.AccountStatus.Paused {
+		add("status", fmt.Errorf("unknown status %q", input.Status))
+	}
+	if input.TOTPSeed != "" && !secret.IsEncrypted(input.TOTPSeed) {
+		if _, err := totp.DecodeSeed(input.TOTPSeed); err != nil {
+			add("totpSeed", err)
+		}
+	}
+	for i, probe := range input.Probes {
+		if probe == nil || !strings.Contains(probe.API, "::/") {
+			add(fmt.Sprintf("probes[%d].api", i), errors.New("must look like GET::/path"))
+		}
+	}
+	return problems
+}
+
+// CreateAccount registers a new test account.
+func CreateAccount(input *model.Account) *common.APIResponse {
+	if failure := fileManaged(); failure != nil {
+		return failure
+	}
+	return createAccount(input)
+}
+
+func createAccount(input *model.Account) *common.APIResponse {
+	if err := validateAccount(input, false); err != nil {
+		return obj.WithInvalidInput(err)
+	}
+
+	if existed := accountExisted(input.Username, input.DomainType); existed != nil {
+		return existed
+	}
+
+	password, err := secret.Encrypt(input.Password)
+	if err != nil {
+		return encryptionFailed(err)
+	}
+	seed, err := secret.Encrypt(input.TOTPSeed)
+	if err != nil {
+		return encryptionFailed(err)
+	}
+
+	input.ID = nil
+	input.CreatedTime = nil
+	input.LastUpdatedTime = nil
+	input.LastStatus = ""
+	input.LastAttemptTime = nil
+	input.ConsecutiveFailures = nil
+	input.PausedTime = nil
+	input.PauseReason = ""
+	input.LeaseHolder = ""
+	input.LeaseExpiredTime = nil
+	input.Password = password
+	input.TOTPSeed = seed
+	if input.Status == "" {
+		input.Status = model.AccountStatus.Active
+	}
+	return hidePasswords(model.DBAccount.Create(input))
+}
+
+// accountExisted refuses a username already registered in domainType.
+func accountExisted(username, domainType string) *common.APIResponse {
+	existed := model.DBAccount.QueryOne(model.Account{
+		Username:   username,
+		DomainType: domainType,
+	})
+	if existed.Status != common.APIStatus.Ok {
+		return nil
+	}
+	return &common.APIResponse{
+		Status:    common.APIStatus.Existed,
+		Message:   "Account " + username + " already exists in " + domainType,
+		ErrorCode: "ACCOUNT_EXISTED",
+	}
+}
+
+// GetAccountList returns registered accounts matching the filter.
+func GetAccountList(filter *model.Account, offset, limit int64) *common.APIResponse {
+	query := model.Account{
+		Username:   filter.Username,
+		Type:       filter.Type,
+		DomainType: filter.DomainType,
+		Status:     filter.Status,
+	}
+	resp := model.DBAccount.Query(query, offset, limit, &bson.M{"_id": 1})
+	if resp.Status == common.APIStatus.Ok {
+		resp.Total = model.DBAccount.Count(query).Total
+	}
+	return hidePasswords(resp)
+}
+
+// UpdateAccount applies the non-empty fields of input to the account.
+func UpdateAccount(id string, input *model.Account) *common.APIResponse {
+	if failure := fileManaged(); failure != nil {
+		return failure
+	}
+	return updateAccount(id, input)
+}
+
+func updateAccount(id string, input *model.Account) *common.APIResponse {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return obj.WithInvalidInput(err)
+	}
+	if err = validateAccount(input, true); err != nil {
+		return obj.WithInvalidInput(err)
+	}
+	// The unique index refuses a rename onto another account, which the SDK
+	// would report as the account not being found.
+	if input.Username != "" || input.DomainType != "" {
+		current := model.DBAccount.QueryOne(bson.M{"_id": oid})
+		if current.Status == common.APIStatus.NotFound {
+			return accountNotFound()
+		}
+		if current.Status != common.APIStatus.Ok {
+			return current
+		}
+		if username, domainType, renamed := renamedTo(current.Data.([]*model.Account)[0], input); renamed {
+			if existed := accountExisted(username, domainType); existed != nil {
+				return existed
+			}
+		}
+	}
+
+	password, err := secret.Encrypt(input.Password)
+	if err != nil {
+		return encryptionFailed(err)
+	}
+	seed, err := secret.Encrypt(input.TOTPSeed)
+	if err != nil {
+		return encryptionFailed(err)
+	}
+
+	updater := model.Account{
+		Username:   input.Username,
+		Password:   password,
+		TOTPSeed:   seed,
+		Type:       input.Type,
+		DomainType: input.DomainType,
+		Status:     input.Status,
+		Owner:      input.Owner,
+		Probes:     input.Probes,
+		Tags:       input.Tags,
+	}
+	if input.Status == model.AccountStatus.Active {
+		updater.ConsecutiveFailures = obj.WithInt(0)
+	}
+	resp := model.DBAccount.UpdateOne(bson.M{"_id": oid}, updater)
+	if resp.Status == common.APIStatus.NotFound {
+		return accountNotFound()
+	}
+	return hidePasswords(resp)
+}
+
+// renamedTo returns the username and environment an update gives the
+// account, and whether they differ from its current ones.
+func renamedTo(account, input *model.Account) (username, domainType string, renamed bool) {
+	username, domainType = account.Username, account.DomainType
+	if input.Username != "" {
+		username = input.Username
+	}
+	if input.DomainType != "" {
+		domainType = input.DomainType
+	}
+	return username, domainType, username != account.Username || domainType != account.DomainType
+}
+
+// DisableAccount keeps the account but excludes it from login runs.
+func DisableAccount(id string) *common.APIResponse {
+	return UpdateAccount(id, &model.Account{Status: model.AccountStatus.Disabled})
+}
+
+// EnableAccount puts a paused or disabled account back into login runs and
+// clears its failure count. With an account file, which decides what is
+// disabled, only a paused account can be enabled.
+func EnableAccount(id string) *common.APIResponse {
+	if accountFile == nil {
+		return updateAccount(id, &model.Account{Status: model.AccountStatus.Active})
+	}
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return obj.WithInvalidInput(err)
+	}
+	resp := model.DBAccount.UpdateOne(bson.M{"_id": oid, "status": model.AccountStatus.Paused}, model.Account{
+		Status:              model.AccountStatus.Active,
+		ConsecutiveFailures: obj.WithInt(0),
+	})
+	if resp.Status == common.APIStatus.NotFound {
+		if model.DBAccount.QueryOne(bson.M{"_id": oid}).Status != common.APIStatus.Ok {
+			return accountNotFound()
+		}
+		failure := fileManaged()
+		failure.Message = "Only a paused account can be enabled, the status of the others is set in " + accountFile.path
+		return failure
+	}
+	return hidePasswords(resp)
+}
+
+// DeleteAccount removes the account from the registry.
+func DeleteAccount(id string) *common.APIResponse {
+	if failure := fileManaged(); failure != nil {
+		return failure
+	}
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return obj.WithInvalidInput(err)
+	}
+	if model.DBAccount.QueryOne(bson.M{"_id": oid}).Status != common.APIStatus.Ok {
+		return accountNotFound()
+	}
+	return model.DBAccount.Delete(bson.M{"_id": oid})
+}
+
+// getActiveAccounts pages through every active account in the registry,
+// restricted to one environment when domainType is set.
+func getActiveAccounts(domainType string) ([]*model.Account, error) {
+	return queryAccounts(model.Account{DomainType: domainType, Status: model.AccountStatus.Active})
+}
+
+// ListAccounts pages through every registered account, whatever its status,
+// restricted to one environment when domainType is set.
+func ListAccounts(domainType string) ([]*model.Account, error) {
+	return queryAccounts(model.Account{DomainType: domainType})
+}
+
+func queryAccounts(query model.Account) ([]*model.Account, error) {
+	var accounts []*model.Account
+	for offset := int64(0); ; offset += accountPageSize {
+		resp := model.DBAccount.Query(query, offset, accountPageSize, &bson.M{"_id": 1})
+		if resp.Status == common.APIStatus.NotFound {
+			break
+		}
+		if resp.Status != common.APIStatus.Ok {
+			return nil, errors.New(resp.Message)
+		}
+
+		page := resp.Data.([]*model.Account)
+		accounts = append(accounts, page...)
+		if len(page) < accountPageSize {
+			break
+		}
+	}
+	return accounts, nil
+}
+
+// GetAccount returns the registered account of an environment, whatever its
+// status, with its sealed password.
+func GetAccount(domainType, username string) (*model.Account, error) {
+	resp := model.DBAccount.QueryOne(model.Account{DomainType: domainType, Username: username})
+	if resp.Status == common.APIStatus.NotFound {
+		return nil, fmt.Errorf("no account %s in %s", username, domainType)
+	}
+	if resp.Status != common.APIStatus.Ok {
+		return nil, errors.New(resp.Message)
+	}
+	return resp.Data.([]*model.Account)[0], nil
+}
+
+// CheckStoredPasswords fails when the registry holds plaintext passwords or
+// TOTP seeds and INSECURE_DEV is not set.
+func CheckStoredPasswords() error {
+	if secret.AllowPlaintext() {
+		return nil
+	}
+
+	sealed := bson.M{"$not": primitive.Regex{Pattern: "^" + secret.Prefix}}
+	count := model.DBAccount.Count(bson.M{"$or": []bson.M{
+		{"password": sealed},
+		{"totp_seed": bson.M{"$exists": true, "$ne": "", "$not": primitive.Regex{Pattern: "^" + secret.Prefix}}},
+	}})
+	if count.Status != common.APIStatus.Ok {
+		return errors.New(count.Message)
+	}
+	if count.Total > 0 {
+		return fmt.Errorf("%d account(s) hold a plaintext password or TOTP seed, run the reencrypt command", count.Total)
+	}
+	return nil
+}
+
+// ReencryptAccounts seals every stored password and TOTP seed with the
+// primary master key, including plaintext values left over from before
+// encryption.
+func ReencryptAccounts() (int, error) {
+	keyring := secret.Default()
+	if keyring == nil {
+		return 0, secret.ErrNoMasterKey
+	}
+
+	updated := 0
+	for offset := int64(0); ; offset += accountPageSize {
+		resp := model.DBAccount.Query(bson.M{}, offset, accountPageSize, &bson.M{"_id": 1})
+		if resp.Status == common.APIStatus.NotFound {
+			break
+		}
+		if resp.Status != common.APIStatus.Ok {
+			return updated, errors.New(resp.Message)
+		}
+
+		page := resp.Data.([]*model.Account)
+		for _, account := range page {
+			password, err := reseal(keyring, account.Password)
+			if err != nil {
+				return updated, fmt.Errorf("account %s (%s): %w", account.Username, account.DomainType, err)
+			}
+			seed, err := reseal(keyring, account.TOTPSeed)
+			if err != nil {
+				return updated, fmt.Errorf("account %s (%s): totpSeed: %w", account.Username, account.DomainType, err)
+			}
+			if password == "" && seed == "" {
+				continue
+			}
+
+			result := model.DBAccount.UpdateOne(bson.M{"_id": account.ID}, model.Account{Password: password, TOTPSeed: seed})
+			if result.Status != common.APIStatus.Ok {
+				return updated, errors.New(result.Message)
+			}
+			updated++
+		}
+		if len(page) < accountPageSize {
+			break
+		}
+	}
+	return updated, nil
+}
+
+// reseal returns value sealed with the primary master key, or "" when it is
+// empty or already sealed with it.
+func reseal(keyring *secret.Keyring, value string) (string, error) {
+	if value == "" || !keyring.NeedsRotation(value) {
+		return "", nil
+	}
+	if secret.IsEncrypted(value) {
+		plaintext, err := keyring.Decrypt(value)
+		if err != nil {
+			return "", err
+		}
+		value = plaintext
+	}
+	return keyring.Encrypt(value)
+}
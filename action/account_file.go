@@ -0,0 +1,350 @@
+package action
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"example.com/micro/config"
+	"example.com/micro/model"
+	"example.com/micro/model/core/obj"
+	"example.com/micro/secret"
+	"fmt"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AccountFile is a JSON account file, in the format of the seed file, that
+// defines the accounts of the registry, e.g. a mounted ConfigMap. Each valid
+// version is synced into the registry, which login runs, leases, tokens and
+// the accounts API keep reading; the registry keeps the login state (last
+// status, failures, automatic pause, lease) of the accounts.
+type AccountFile struct {
+	path string
+	set  atomic.Pointer[accountSet]
+
+	// mu serializes reloads; seen is the version of the last content read,
+	// applied or rejected, so that it is reported once, and synced the
+	// version last written to the registry.
+	mu     sync.Mutex
+	seen   string
+	synced string
+}
+
+// accountSet is one version of the account file. Definitions hold each
+// account as the file defines it, by session key, to tell changed accounts.
+type accountSet struct {
+	version     string
+	accounts    []*model.Account
+	definitions map[string]string
+}
+
+// accountFile is set when the registry is defined by an account file.
+var accountFile *AccountFile
+
+// SetAccountFile makes file define the accounts of the registry.
+func SetAccountFile(file *AccountFile) {
+	accountFile = file
+}
+
+// NewAccountFile loads the account file, which must be valid.
+func NewAccountFile(path string) (*AccountFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	accounts, invalid, err := validateAccounts(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(invalid) > 0 {
+		return nil, &SeedError{Path: path, Invalid: invalid}
+	}
+
+	f := &AccountFile{path: path}
+	set := newAccountSet(digest(content), accounts)
+	f.set.Store(set)
+	f.seen = set.version
+	fmt.Printf("Loaded %d account(s) from %s, version %s\n", len(accounts), path, set.version)
+	return f, nil
+}
+
+func newAccountSet(version string, accounts []*model.Account) *accountSet {
+	set := &accountSet{version: version, accounts: accounts, definitions: make(map[string]string, len(accounts))}
+	for _, account := range accounts {
+		if account.Status == "" {
+			account.Status = model.AccountStatus.Active
+		}
+		definition, _ := json.Marshal(account)
+		set.definitions[sessionKey(account.DomainType, account.Username)] = string(definition)
+	}
+	return set
+}
+
+// digest names a version of the file by its content.
+func digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:6])
+}
+
+// Version is the digest of the account file in use.
+func (f *AccountFile) Version() string {
+	return f.set.Load().version
+}
+
+// SyncAccountFile writes the account file, when one is set, into the
+// registry. It runs once the database is connected, before any login run.
+// While another instance runs, the sync is left to the next reload.
+func SyncAccountFile() error {
+	if accountFile == nil {
+		return nil
+	}
+	accountFile.mu.Lock()
+	defer accountFile.mu.Unlock()
+	return accountFile.syncAsLeader()
+}
+
+// Reload reads the account file and, when its content changed, validates it
+// and syncs the new version into the registry. A version with problems is
+// rejected and the registry is left as it is. Either outcome is logged and
+// recorded in account_reload, once per version. Reload returns nil when the
+// file did not change. The registry is not synced while a login run is in
+// flight on any instance, so that a run sees one version from start to end;
+// a version that could not be synced is retried by the next call.
+func (f *AccountFile) Reload() (*model.AccountReload, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	version := digest(content)
+	if version == f.seen {
+		return nil, f.retrySync()
+	}
+
+	current := f.set.Load()
+	hostname, _ := os.Hostname()
+	reload := &model.AccountReload{
+		Source:          f.path,
+		Version:         version,
+		PreviousVersion: current.version,
+		Hostname:        hostname,
+		ReloadTime:      obj.WithTime(time.Now()),
+	}
+	accounts, invalid, err := validateAccounts(content)
+	if err != nil {
+		invalid = []error{err}
+	}
+	if len(invalid) > 0 {
+		reload.Status = model.ReloadStatus.Rejected
+		for _, problem := range invalid {
+			reload.Problems = append(reload.Problems, problem.Error())
+		}
+		fmt.Printf("Rejected version %s of %s, keeping version %s:\n  %s\n",
+			version, f.path, current.version, strings.Join(reload.Problems, "\n  "))
+	} else {
+		next := newAccountSet(version, accounts)
+		added, removed, changed := diffAccounts(current, next)
+		f.set.Store(next)
+
+		reload.Status = model.ReloadStatus.Applied
+		reload.Total = obj.WithInt(len(accounts))
+		reload.Added = obj.WithInt(added)
+		reload.Removed = obj.WithInt(removed)
+		reload.Changed = obj.WithInt(changed)
+		fmt.Printf("Reloaded %s from version %s to %s: %d account(s), %d added, %d removed, %d changed\n",
+			f.path, current.version, version, len(accounts), added, removed, changed)
+	}
+
+	f.seen = version
+	model.DBAccountReload.Create(reload)
+	return reload, f.retrySync()
+}
+
+// retrySync syncs the version in use when the registry does not hold it yet,
+// unless a login run is in flight here or on another instance.
+func (f *AccountFile) retrySync() error {
+	if f.synced == f.set.Load().version || !runLock.TryLock() {
+		return nil
+	}
+	defer runLock.Unlock()
+	return f.syncAsLeader()
+}
+
+// syncAsLeader syncs while holding the run lock the instances share, so that
+// the registry never changes under the run of another instance. The sync is
+// skipped while the lock is held.
+func (f *AccountFile) syncAsLeader() error {
+	grant, err := leaderLock.Acquire("sync-" + primitive.NewObjectID().Hex())
+	if errors.Is(err, ErrRunInProgress) {
+		fmt.Printf("Version %s of %s not synced yet: %v\n", f.set.Load().version, f.path, err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("sync version %s of %s: %w", f.set.Load().version, f.path, err)
+	}
+	hb := keepLock(grant)
+	defer hb.release()
+	return f.sync()
+}
+
+// sync writes the version in use into the registry: accounts of the file
+// are created or updated, the others are deleted.
+func (f *AccountFile) sync() error {
+	set := f.set.Load()
+	registered, err := ListAccounts("")
+	if err != nil {
+		return fmt.Errorf("sync version %s of %s: %w", set.version, f.path, err)
+	}
+	byKey := make(map[string]*model.Account, len(registered))
+	for _, account := range registered {
+		byKey[sessionKey(account.DomainType, account.Username)] = account
+	}
+
+	for _, account := range set.accounts {
+		key := sessionKey(account.DomainType, account.Username)
+		existing := byKey[key]
+		delete(byKey, key)
+
+		var resp *common.APIResponse
+		if existing == nil {
+			input := *account
+			resp = createAccount(&input)
+		} else {
+			updater, err := fileUpdate(existing, account)
+			if err != nil {
+				return fmt.Errorf("sync %s (%s): %w", account.Username, account.DomainType, err)
+			}
+			resp = model.DBAccount.UpdateOneWithOption(bson.M{"_id": existing.ID}, updater)
+		}
+		if resp.Status != common.APIStatus.Ok {
+			return fmt.Errorf("sync %s (%s): %s", account.Username, account.DomainType, resp.Message)
+		}
+	}
+	for _, stale := range byKey {
+		if resp := model.DBAccount.Delete(bson.M{"_id": stale.ID}); resp.Status != common.APIStatus.Ok {
+			return fmt.Errorf("sync: delete %s (%s): %s", stale.Username, stale.DomainType, resp.Message)
+		}
+	}
+
+	f.synced = set.version
+	fmt.Printf("Synced version %s of %s into the registry: %d account(s), %d deleted\n",
+		set.version, f.path, len(set.accounts), len(byKey))
+	return nil
+}
+
+// fileUpdate applies the definition of account in the file to the registered
+// one. An account the service paused stays paused until its password or TOTP
+// seed changes in the file.
+func fileUpdate(registered, account *model.Account) (bson.M, error) {
+	fields := bson.M{
+		"type":   account.Type,
+		"owner":  account.Owner,
+		"tags":   account.Tags,
+		"probes": account.Probes,
+	}
+	rotated := !sameSecret(registered.Password, account.Password) || !sameSecret(registered.TOTPSeed, account.TOTPSeed)
+	if rotated {
+		password, err := secret.Encrypt(account.Password)
+		if err != nil {
+			return nil, err
+		}
+		seed, err := secret.Encrypt(account.TOTPSeed)
+		if err != nil {
+			return nil, err
+		}
+		fields["password"] = password
+		fields["totp_seed"] = seed
+		fields["consecutive_failures"] = 0
+	}
+	if account.Status != model.AccountStatus.Active || registered.Status != model.AccountStatus.Paused || rotated {
+		fields["status"] = account.Status
+	}
+	fields["last_updated_time"] = time.Now()
+	return bson.M{"$set": fields}, nil
+}
+
+// sameSecret reports whether the sealed value of the registry holds the
+// value of the file, plaintext or sealed.
+func sameSecret(stored, value string) bool {
+	opened, err := secret.Decrypt(stored)
+	if err != nil {
+		return false
+	}
+	if secret.IsEncrypted(value) {
+		if value, err = secret.Decrypt(value); err != nil {
+			return false
+		}
+	}
+	return opened == value
+}
+
+func diffAccounts(previous, next *accountSet) (added, removed, changed int) {
+	for key, definition := range next.definitions {
+		old, ok := previous.definitions[key]
+		switch {
+		case !ok:
+			added++
+		case old != definition:
+			changed++
+		}
+	}
+	for key := range previous.definitions {
+		if _, ok := next.definitions[key]; !ok {
+			removed++
+		}
+	}
+	return added, removed, changed
+}
+
+// fileManaged refuses a change of account definition when they come from
+// an account file: the next sync would undo it.
+func fileManaged() *common.APIResponse {
+	if accountFile == nil {
+		return nil
+	}
+	return &common.APIResponse{
+		Status:    common.APIStatus.Forbidden,
+		Message:   "Accounts are defined in " + accountFile.path + ", change them there",
+		ErrorCode: "ACCOUNT_FILE_MANAGED",
+	}
+}
+
+// WatchAccountSource reloads the account file every ACCOUNT_RELOAD_INTERVAL
+// until ctx is done, when the registry is defined by one.
+func WatchAccountSource(ctx context.Context) {
+	if accountFile == nil {
+		return
+	}
+	tick := time.NewTicker(config.AccountReloadInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		if _, err := accountFile.Reload(); err != nil {
+			fmt.Println("Cannot reload accounts: ", err)
+		}
+	}
+}
+
+// GetAccountReloadList returns the versions of the account file seen by the
+// service, most recent first.
+func GetAccountReloadList(offset, limit int64) *common.APIResponse {
+	resp := model.DBAccountReload.Query(bson.M{}, offset, limit, &bson.M{"reload_time": -1})
+	if resp.Status == common.APIStatus.Ok {
+		resp.Total = model.DBAccountReload.Count(bson.M{}).Total
+	}
+	return resp
+}
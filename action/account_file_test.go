@@ -0,0 +1,77 @@
+package action
+
+import (
+	"example.com/micro/fakeauth"
+	"example.com/micro/model"
+	"os"
+	"testing"
+)
+
+func TestAccountFileReload(t *testing.T) {
+	setupFakeAuth(t, fakeauth.Config{})
+	path := t.TempDir() + "/accounts.json"
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`[
+		{"username": "a.stg", "password": "secret", "type": "EMPLOYEE", "domainType": "stg"},
+		{"username": "b.stg", "password": "secret", "type": "EMPLOYEE", "domainType": "stg"}
+	]`)
+	file, err := NewAccountFile(path)
+	if err != nil {
+		t.Fatalf("NewAccountFile: %v", err)
+	}
+	v1 := file.Version()
+
+	// Without Mongo the registry cannot be synced: every call retries.
+	write(`[{"username": "a.stg", "password": "secret", "type": "EMPLOYE", "domainType": "stg"}]`)
+	reload, err := file.Reload()
+	if reload == nil || reload.Status != model.ReloadStatus.Rejected || len(reload.Problems) != 1 {
+		t.Fatalf("Reload of an invalid file = %+v; want REJECTED with 1 problem", reload)
+	}
+	if err == nil {
+		t.Fatal("Reload reported no error while the registry is unavailable")
+	}
+	if file.Version() != v1 {
+		t.Fatalf("after rejection: version %s, want %s", file.Version(), v1)
+	}
+	if again, err := file.Reload(); again != nil || err == nil {
+		t.Fatalf("rejected version reported again (%+v) or sync not retried (%v)", again, err)
+	}
+
+	write(`[
+		{"username": "a.stg", "password": "secret", "type": "EMPLOYEE", "domainType": "stg"},
+		{"username": "b.stg", "password": "rotated", "type": "EMPLOYEE", "domainType": "stg"},
+		{"username": "c.dev", "password": "secret", "type": "CUSTOMER", "domainType": "dev"}
+	]`)
+	reload, _ = file.Reload()
+	if reload == nil || reload.Status != model.ReloadStatus.Applied {
+		t.Fatalf("Reload = %+v; want APPLIED", reload)
+	}
+	if *reload.Total != 3 || *reload.Added != 1 || *reload.Removed != 0 || *reload.Changed != 1 || reload.PreviousVersion != v1 {
+		t.Fatalf("reload %d total, %d added, %d removed, %d changed from %s; want 3/1/0/1 from %s",
+			*reload.Total, *reload.Added, *reload.Removed, *reload.Changed, reload.PreviousVersion, v1)
+	}
+	if file.Version() != reload.Version {
+		t.Fatalf("version %s in use, want %s", file.Version(), reload.Version)
+	}
+	if again, _ := file.Reload(); again != nil {
+		t.Fatalf("unchanged file reloaded: %+v", again)
+	}
+
+	// The registry is left alone while another instance runs.
+	lock := &memoryLock{}
+	SetLeaderLock(lock)
+	other, _ := lock.Acquire("other-run")
+	write(`[{"username": "a.stg", "password": "secret", "type": "EMPLOYEE", "domainType": "stg"}]`)
+	if reload, err := file.Reload(); reload == nil || err != nil {
+		t.Fatalf("Reload during another run = %+v, %v; want applied and sync skipped", reload, err)
+	}
+	lock.Release(other)
+	if _, err := file.Reload(); err == nil {
+		t.Fatal("sync not retried once the other run is over")
+	}
+}
@@ -0,0 +1,29 @@
+package action
+
+import (
+	"example.com/micro/model"
+	"testing"
+)
+
+func TestRenamedTo(t *testing.T) {
+	account := &model.Account{Username: "alice.stg", DomainType: "stg"}
+	for _, tc := range []struct {
+		name       string
+		input      *model.Account
+		username   string
+		domainType string
+		renamed    bool
+	}{
+		{"status only", &model.Account{Status: model.AccountStatus.Disabled}, "alice.stg", "stg", false},
+		{"same key", &model.Account{Username: "alice.stg", DomainType: "stg"}, "alice.stg", "stg", false},
+		{"username", &model.Account{Username: "bob.stg"}, "bob.stg", "stg", true},
+		{"environment", &model.Account{DomainType: "dev"}, "alice.stg", "dev", true},
+		{"both", &model.Account{Username: "bob.dev", DomainType: "dev"}, "bob.dev", "dev", true},
+	} {
+		username, domainType, renamed := renamedTo(account, tc.input)
+		if username != tc.username || domainType != tc.domainType || renamed != tc.renamed {
+			t.Errorf("%s: renamedTo = %s, %s, %v; want %s, %s, %v",
+				tc.name, username, domainType, renamed, tc.username, tc.domainType, tc.renamed)
+		}
+	}
+}
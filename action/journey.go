@@ -0,0 +1,197 @@
+package action
+
+import (
+	"errors"
+	"example.com/micro/client"
+	"example.com/micro/config"
+	"example.com/micro/cron"
+	"example.com/micro/journey"
+	"example.com/micro/model"
+	"example.com/micro/model/core/obj"
+	"example.com/micro/secret"
+	"fmt"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/common"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/schedule"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"os"
+	"time"
+)
+
+// journeyTopicPrefix prefixes the schedule topic of every journey.
+const journeyTopicPrefix = "JOURNEY:"
+
+var journeys = make(map[string]*journey.Journey)
+
+// SetJourneys replaces the known journeys after checking that each targets a
+// known environment and has a valid cron expression.
+func SetJourneys(list []*journey.Journey) error {
+	registry := make(map[string]*journey.Journey, len(list))
+	for _, j := range list {
+		if _, ok := config.GetEnvironment(j.Environment); !ok {
+			return fmt.Errorf("journey %s: unknown environment %q", j.Name, j.Environment)
+		}
+		if j.Cron != "" {
+			if _, err := journeySpec(j); err != nil {
+				return fmt.Errorf("journey %s: %w", j.Name, err)
+			}
+		}
+		registry[j.Name] = j
+	}
+	journeys = registry
+	return nil
+}
+
+func journeySpec(j *journey.Journey) (*cron.Schedule, error) {
+	timeZone := j.TimeZone
+	if timeZone == "" {
+		timeZone = config.AutoLoginTimeZone
+	}
+	return cron.Parse(j.Cron, timeZone)
+}
+
+// InitJourneys registers a schedule topic for every journey with a cron
+// expression. The journey file owns the schedule: a changed cron or time zone
+// replaces the stored one on startup, and the topic of a journey removed or
+// no longer scheduled is deleted.
+func InitJourneys() {
+	scheduled := make([]string, 0, len(journeys))
+	for name, j := range journeys {
+		if j.Cron == "" {
+			continue
+		}
+		spec, err := journeySpec(j)
+		if err != nil {
+			continue
+		}
+		topic := journeyTopicPrefix + name
+		scheduled = append(scheduled, topic)
+		nextRun := spec.Next(time.Now())
+		model.EnsureSchedule(&model.Schedule{
+			Topic:    topic,
+			NextRun:  &nextRun,
+			Cron:     j.Cron,
+			TimeZone: spec.Location().String(),
+		})
+		model.SetScheduleCron(topic, j.Cron, spec.Location().String(), nextRun)
+		processor[topic] = pausable(RunScheduledJourney)
+	}
+	if resp := model.DeleteSchedules(journeyTopicPrefix, scheduled); resp.Status != common.APIStatus.Ok {
+		fmt.Println("Error when deleting the topics of removed journeys: ", resp.Message)
+	}
+}
+
+// RunScheduledJourney runs the journey named by the topic and computes its
+// next run from the stored cron expression.
+func RunScheduledJourney(timeNew *time.Time, scheduleConfig *schedule.Config) (error, string, *time.Time) {
+	spec, err := getScheduleSpec(scheduleConfig.Topic)
+	if err != nil {
+		return err, "", nil
+	}
+	name := scheduleConfig.Topic[len(journeyTopicPrefix):]
+	j, ok := journeys[name]
+	if !ok {
+		return errors.New("journey " + name + " is no longer defined"), "", nil
+	}
+
+	run := RunJourney(j, model.RunTrigger.Schedule)
+	nextTime := spec.Next(time.Now())
+	if nextTime.IsZero() {
+		return errors.New("cron expression " + spec.String() + " never fires"), "", nil
+	}
+	return nil, fmt.Sprintf("journey run %s %s", run.RunID, run.Status), &nextTime
+}
+
+// RunJourneyByName runs a journey on demand.
+func RunJourneyByName(name string) *common.APIResponse {
+	j, ok := journeys[name]
+	if !ok {
+		return &common.APIResponse{
+			Status:    common.APIStatus.NotFound,
+			Message:   "Journey " + name + " not found",
+			ErrorCode: "JOURNEY_NOT_FOUND",
+		}
+	}
+	run := RunJourney(j, model.RunTrigger.API)
+	return &common.APIResponse{
+		Status:  common.APIStatus.Ok,
+		Message: "Journey " + name + " " + run.Status,
+		Data:    []*model.JourneyRun{run},
+	}
+}
+
+// RunJourney executes the journey once and records the outcome.
+func RunJourney(j *journey.Journey, trigger string) *model.JourneyRun {
+	hostname, _ := os.Hostname()
+	run := &model.JourneyRun{
+		RunID:       primitive.NewObjectID().Hex(),
+		Journey:     j.Name,
+		Environment: j.Environment,
+		Trigger:     trigger,
+		Hostname:    hostname,
+		Status:      model.RunStatus.Failed,
+		StartTime:   obj.WithTime(time.Now()),
+	}
+	defer func() {
+		run.EndTime = obj.WithTime(time.Now())
+		model.DBJourneyRun.Create(run)
+		printJourneyReport(run)
+	}()
+
+	env, ok := config.GetEnvironment(j.Environment)
+	if !ok {
+		run.Message = fmt.Sprintf("unknown environment %q", j.Environment)
+		return run
+	}
+
+	vars := make(map[string]interface{})
+	if j.Login != nil {
+		token, failure := sessionToken(j.Environment, j.Login.Username)
+		if failure != nil {
+			run.Message = "login: " + failure.Message
+			return run
+		}
+		plaintext, err := secret.Decrypt(token.Token)
+		if err != nil {
+			run.Message = "login: cannot decrypt token: " + err.Error()
+			return run
+		}
+		vars["token"] = plaintext
+	}
+
+	run.Steps = j.Run(client.NewClient(env.Domain, -1), vars)
+	run.Status = model.RunStatus.Success
+	for _, step := range run.Steps {
+		if step.Status == journey.StepFailed {
+			run.Status = model.RunStatus.Failed
+			run.Message = "step " + step.Name + ": " + step.Message
+			break
+		}
+	}
+	return run
+}
+
+func printJourneyReport(run *model.JourneyRun) {
+	fmt.Printf("Journey %s run %s on %s: %s", run.Journey, run.RunID, run.Environment, run.Status)
+	for _, step := range run.Steps {
+		fmt.Printf("\n- %s %s (%d, %dms) %s", step.Status, step.Name, step.HTTPCode, step.LatencyMs, step.Message)
+	}
+	if run.Message != "" && len(run.Steps) == 0 {
+		fmt.Printf("\n%s", run.Message)
+	}
+	fmt.Println()
+}
+
+// GetJourneyRunList returns journey runs, most recent first.
+func GetJourneyRunList(filter *model.JourneyRun, offset, limit int64) *common.APIResponse {
+	query := model.JourneyRun{
+		Journey:     filter.Journey,
+		Environment: filter.Environment,
+		Status:      filter.Status,
+	}
+	resp := model.DBJourneyRun.Query(query, offset, limit, &bson.M{"start_time": -1})
+	if resp.Status == common.APIStatus.Ok {
+		resp.Total = model.DBJourneyRun.Count(query).Total
+	}
+	return resp
+}
@@ -0,0 +1,253 @@
+package action
+
+import (
+	"errors"
+	"example.com/micro/config"
+	"example.com/micro/model"
+	"example.com/micro/model/core/obj"
+	"example.com/micro/secret"
+	"fmt"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// LeaseRequest selects the account to check out. Empty filters match any
+// account; TTLSeconds defaults to config.LeaseTTL.
+type LeaseRequest struct {
+	Type       string `json:"type,omitempty"`
+	DomainType string `json:"domainType,omitempty"`
+	Tag        string `json:"tag,omitempty"`
+	Holder     string `json:"holder"`
+	Grant      string `json:"grant,omitempty"`
+	TTLSeconds int    `json:"ttlSeconds,omitempty"`
+}
+
+func leaseNotFound(leaseID string) *common.APIResponse {
+	return &common.APIResponse{
+		Status:    common.APIStatus.NotFound,
+		Message:   "Lease " + leaseID + " not found or already over",
+		ErrorCode: "LEASE_NOT_FOUND",
+	}
+}
+
+// leaseTTL turns requested seconds into a lease duration capped by
+// config.LeaseMaxTTL.
+func leaseTTL(seconds int) (time.Duration, error) {
+	if seconds < 0 {
+		return 0, errors.New("ttlSeconds must be positive")
+	}
+	ttl := config.LeaseTTL
+	if seconds > 0 {
+		ttl = time.Duration(seconds) * time.Second
+	}
+	if ttl > config.LeaseMaxTTL {
+		ttl = config.LeaseMaxTTL
+	}
+	return ttl, nil
+}
+
+// AcquireLease checks out a free active account matching the request, for
+// the caller only, until the lease expires or is released. The account is
+// picked and marked in one atomic update, so concurrent requests never get
+// the same account.
+func AcquireLease(input *LeaseRequest) *common.APIResponse {
+	if input.Holder == "" {
+		return obj.WithInvalidInput(errors.New("holder is required"))
+	}
+	if input.Grant == "" {
+		input.Grant = model.LeaseGrant.Credentials
+	}
+	if input.Grant != model.LeaseGrant.Credentials && input.Grant != model.LeaseGrant.Token {
+		return obj.WithInvalidInput(fmt.Errorf("unknown grant %q", input.Grant))
+	}
+	ttl, err := leaseTTL(input.TTLSeconds)
+	if err != nil {
+		return obj.WithInvalidInput(err)
+	}
+	expireLeases()
+
+	now := time.Now()
+	query := bson.M{
+		"status": model.AccountStatus.Active,
+		"$or": []bson.M{
+			{"lease_expired_time": bson.M{"$exists": false}},
+			{"lease_expired_time": bson.M{"$lte": now}},
+		},
+	}
+	if input.Type != "" {
+		query["type"] = input.Type
+	}
+	if input.DomainType != "" {
+		query["domain_type"] = input.DomainType
+	}
+	if input.Tag != "" {
+		query["tags"] = input.Tag
+	}
+
+	lease := &model.Lease{
+		LeaseID:     primitive.NewObjectID().Hex(),
+		Holder:      input.Holder,
+		Grant:       input.Grant,
+		Status:      model.LeaseStatus.Active,
+		StartTime:   &now,
+		ExpiredTime: obj.WithTime(now.Add(ttl)),
+	}
+	resp := model.DBAccount.UpdateOne(query, model.Account{
+		LeaseID:          lease.LeaseID,
+		LeaseHolder:      lease.Holder,
+		LeaseExpiredTime: lease.ExpiredTime,
+	})
+	if resp.Status != common.APIStatus.Ok {
+		return &common.APIResponse{
+			Status:    common.APIStatus.NotFound,
+			Message:   "No free account matches the request",
+			ErrorCode: "NO_FREE_ACCOUNT",
+		}
+	}
+	account := resp.Data.([]*model.Account)[0]
+	lease.Username = account.Username
+	lease.DomainType = account.DomainType
+	lease.Type = account.Type
+	model.DBLease.Create(lease)
+
+	if failure := grantLease(lease, account); failure != nil {
+		releaseLease(lease.LeaseID, now)
+		return failure
+	}
+	return &common.APIResponse{
+		Status:  common.APIStatus.Ok,
+		Message: "Account " + account.Username + " leased until " + lease.ExpiredTime.Format(time.RFC3339),
+		Data:    []*model.Lease{lease},
+	}
+}
+
+// grantLease fills the password or a session token from a fresh login, so
+// that the holder does not share the session of a previous holder. The token
+// is the holder's only: it is not stored with the sessions of the accounts.
+// The login counts against the account like the ones of a run, so a wrong
+// password pauses it and the next requests get another account.
+func grantLease(lease *model.Lease, account *model.Account) *common.APIResponse {
+	if lease.Grant == model.LeaseGrant.Token {
+		attempt, result := signIn("", account)
+		recordAttempt(account, attempt)
+		if attempt.Status != model.AttemptStatus.Success {
+			return &common.APIResponse{
+				Status:    common.APIStatus.Error,
+				Message:   "Login failed: " + attempt.Message,
+				ErrorCode: "LOGIN_FAILED",
+			}
+		}
+		if result.Token == "" {
+			return tokenNotFound(account)
+		}
+		lease.Token = result.Token
+		return nil
+	}
+
+	plaintext, err := secret.Decrypt(account.Password)
+	if err != nil {
+		return &common.APIResponse{
+			Status:    common.APIStatus.Error,
+			Message:   "Cannot decrypt secret: " + err.Error(),
+			ErrorCode: "DECRYPTION_FAILED",
+		}
+	}
+	lease.Password = plaintext
+	return nil
+}
+
+// RenewLease extends a lease in force by the requested TTL from now.
+func RenewLease(leaseID string, ttlSeconds int) *common.APIResponse {
+	ttl, err := leaseTTL(ttlSeconds)
+	if err != nil {
+		return obj.WithInvalidInput(err)
+	}
+
+	now := time.Now()
+	expiredTime := now.Add(ttl)
+	resp := model.DBAccount.UpdateOne(bson.M{
+		"lease_id":           leaseID,
+		"lease_expired_time": bson.M{"$gt": now},
+	}, model.Account{LeaseExpiredTime: &expiredTime})
+	if resp.Status != common.APIStatus.Ok {
+		return leaseNotFound(leaseID)
+	}
+	return model.DBLease.UpdateOne(model.Lease{LeaseID: leaseID}, model.Lease{ExpiredTime: &expiredTime})
+}
+
+// ReleaseLease gives the account back to the pool.
+func ReleaseLease(leaseID string) *common.APIResponse {
+	if !releaseLease(leaseID, time.Now()) {
+		return leaseNotFound(leaseID)
+	}
+	return model.DBLease.QueryOne(model.Lease{LeaseID: leaseID})
+}
+
+func releaseLease(leaseID string, now time.Time) bool {
+	resp := model.DBAccount.UpdateOne(bson.M{
+		"lease_id":           leaseID,
+		"lease_expired_time": bson.M{"$gt": now},
+	}, model.Account{LeaseExpiredTime: &now})
+	if resp.Status != common.APIStatus.Ok {
+		return false
+	}
+	model.DBLease.UpdateOne(model.Lease{LeaseID: leaseID}, model.Lease{
+		Status:       model.LeaseStatus.Released,
+		ReleasedTime: &now,
+	})
+	return true
+}
+
+// expireLeases closes the history of leases that ran out without being
+// released. Their accounts are already free: a lease is only in force until
+// its expiry.
+func expireLeases() {
+	model.DBLease.UpdateMany(bson.M{
+		"status":       model.LeaseStatus.Active,
+		"expired_time": bson.M{"$lte": time.Now()},
+	}, model.Lease{Status: model.LeaseStatus.Expired})
+}
+
+// GetLeaseList returns leases, most recent first.
+func GetLeaseList(filter *model.Lease, offset, limit int64) *common.APIResponse {
+	expireLeases()
+	query := model.Lease{
+		Username:   filter.Username,
+		DomainType: filter.DomainType,
+		Holder:     filter.Holder,
+		Status:     filter.Status,
+	}
+	resp := model.DBLease.Query(query, offset, limit, &bson.M{"start_time": -1})
+	if resp.Status == common.APIStatus.Ok {
+		resp.Total = model.DBLease.Count(query).Total
+	}
+	return resp
+}
+
+// skipLeased leaves out the accounts checked out at now: logging them in
+// would end the holder's session. The second result is their number.
+func skipLeased(accounts []*model.Account, now time.Time) ([]*model.Account, int) {
+	selected := make([]*model.Account, 0, len(accounts))
+	for _, account := range accounts {
+		if !account.Leased(now) {
+			selected = append(selected, account)
+		}
+	}
+	return selected, len(accounts) - len(selected)
+}
+
+// leasedNow reports whether the account is checked out at now, reading its
+// lease again from the registry: a run's accounts are listed at its start
+// and may be leased since.
+func leasedNow(account *model.Account, now time.Time) bool {
+	if account.ID == nil {
+		return account.Leased(now)
+	}
+	resp := model.DBAccount.QueryOne(bson.M{
+		"_id":                account.ID,
+		"lease_expired_time": bson.M{"$gt": now},
+	})
+	return resp.Status == common.APIStatus.Ok
+}
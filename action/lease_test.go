@@ -0,0 +1,32 @@
+package action
+
+import (
+	"example.com/micro/fakeauth"
+	"example.com/micro/model"
+	"testing"
+	"time"
+)
+
+func TestAutoLoginSkipsLeasedAccounts(t *testing.T) {
+	fake := setupFakeAuth(t, fakeauth.Config{
+		Users: []*fakeauth.User{
+			{Username: "leased.stg", Password: "secret"},
+			{Username: "expired.stg", Password: "secret"},
+		},
+	},
+		leased(account("stg", "leased.stg", "secret"), time.Now().Add(time.Hour)),
+		leased(account("stg", "expired.stg", "secret"), time.Now().Add(-time.Minute)),
+	)
+
+	run, err := RunAutoLogin(RunOption{Trigger: model.RunTrigger.API})
+	if err != nil {
+		t.Fatalf("RunAutoLogin: %v", err)
+	}
+	if *run.Total != 1 || *run.Leased != 1 {
+		t.Fatalf("run over %d account(s) with %d leased, want 1 and 1", *run.Total, *run.Leased)
+	}
+	if fake.Calls("leased.stg") != 0 || fake.Calls("expired.stg") != 1 {
+		t.Fatalf("calls leased=%d expired=%d, want only the expired lease logged in",
+			fake.Calls("leased.stg"), fake.Calls("expired.stg"))
+	}
+}
@@ -0,0 +1,116 @@
+package action
+
+import (
+	"errors"
+	"example.com/micro/config"
+	"example.com/micro/model"
+	"example.com/micro/model/core/obj"
+	"fmt"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/common"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// RunDetail is a login run together with its per-account attempts.
+type RunDetail struct {
+	*model.LoginRun
+	Attempts []*model.LoginAttempt `json:"attempts"`
+}
+
+// TriggerRun starts a login run over every account, one environment or one
+// account, and returns it right away.
+func TriggerRun(opt RunOption) *common.APIResponse {
+	if opt.Username != "" && opt.Environment == "" {
+		return obj.WithInvalidInput(errors.New("environment is required with username"))
+	}
+	if _, ok := config.GetEnvironment(opt.Environment); opt.Environment != "" && !ok {
+		return obj.WithInvalidInput(fmt.Errorf("unknown environment %q", opt.Environment))
+	}
+
+	run, err := StartAutoLogin(opt)
+	if errors.Is(err, ErrRunInProgress) {
+		return &common.APIResponse{
+			Status:    common.APIStatus.Existed,
+			Message:   err.Error(),
+			ErrorCode: "RUN_IN_PROGRESS",
+		}
+	}
+	if err != nil {
+		return &common.APIResponse{
+			Status:  common.APIStatus.Error,
+			Message: "Cannot start run: " + err.Error(),
+		}
+	}
+	return &common.APIResponse{
+		Status:  common.APIStatus.Ok,
+		Message: "Run " + run.RunID + " started",
+		Data:    []*model.LoginRun{run},
+	}
+}
+
+// GetRunList returns login runs, most recent first.
+func GetRunList(filter *model.LoginRun, offset, limit int64) *common.APIResponse {
+	query := model.LoginRun{
+		Trigger:     filter.Trigger,
+		Environment: filter.Environment,
+		Status:      filter.Status,
+	}
+	resp := model.DBLoginRun.Query(query, offset, limit, &bson.M{"start_time": -1})
+	if resp.Status == common.APIStatus.Ok {
+		resp.Total = model.DBLoginRun.Count(query).Total
+	}
+	return resp
+}
+
+// GetRun returns one login run with all of its attempts.
+func GetRun(runID string) *common.APIResponse {
+	if runID == "" {
+		return obj.WithInvalidInput(errors.New("runId is required"))
+	}
+
+	resp := model.DBLoginRun.QueryOne(model.LoginRun{RunID: runID})
+	if resp.Status != common.APIStatus.Ok {
+		return &common.APIResponse{
+			Status:    common.APIStatus.NotFound,
+			Message:   "Run " + runID + " not found",
+			ErrorCode: "RUN_NOT_FOUND",
+		}
+	}
+
+	attempts, err := getRunAttempts(runID)
+	if err != nil {
+		return &common.APIResponse{
+			Status:  common.APIStatus.Error,
+			Message: "Cannot load attempts of run " + runID + ": " + err.Error(),
+		}
+	}
+	detail := &RunDetail{
+		LoginRun: resp.Data.([]*model.LoginRun)[0],
+		Attempts: []*model.LoginAttempt{},
+	}
+	if attempts != nil {
+		detail.Attempts = attempts
+	}
+
+	return &common.APIResponse{
+		Status:  common.APIStatus.Ok,
+		Message: "Query login run successfully.",
+		Data:    []*RunDetail{detail},
+	}
+}
+
+// GetAccountAttempts returns the login history of one account, most recent first.
+func GetAccountAttempts(domainType, username string, offset, limit int64) *common.APIResponse {
+	if domainType == "" || username == "" {
+		return obj.WithInvalidInput(errors.New("domainType and username are required"))
+	}
+
+	query := model.LoginAttempt{
+		DomainType: domainType,
+		Username:   username,
+	}
+	resp := model.DBLoginAttempt.Query(query, offset, limit, &bson.M{"start_time": -1})
+	if resp.Status == common.APIStatus.Ok {
+		resp.Total = model.DBLoginAttempt.Count(query).Total
+	}
+	return resp
+}
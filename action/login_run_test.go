@@ -0,0 +1,40 @@
+package action
+
+import (
+	"example.com/micro/fakeauth"
+	"example.com/micro/model"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/common"
+	"testing"
+)
+
+func TestTriggerRunForOneAccount(t *testing.T) {
+	fake := setupFakeAuth(t, fakeauth.Config{
+		Users: []*fakeauth.User{
+			{Username: "slow.stg", Password: "secret", LatencyMs: 200},
+			{Username: "other.stg", Password: "secret"},
+		},
+	},
+		account("stg", "slow.stg", "secret"),
+		account("stg", "other.stg", "secret"),
+	)
+
+	if resp := TriggerRun(RunOption{Trigger: model.RunTrigger.API, Username: "slow.stg"}); resp.Status != common.APIStatus.Invalid {
+		t.Fatalf("username without environment: %s, want INVALID", resp.Status)
+	}
+
+	resp := TriggerRun(RunOption{Trigger: model.RunTrigger.API, Environment: "stg", Username: "slow.stg"})
+	if resp.Status != common.APIStatus.Ok {
+		t.Fatalf("TriggerRun: %s %s", resp.Status, resp.Message)
+	}
+	if run := resp.Data.([]*model.LoginRun)[0]; run.Status != model.RunStatus.Running || run.Username != "slow.stg" {
+		t.Fatalf("started run %s for %q, want RUNNING for slow.stg", run.Status, run.Username)
+	}
+	if busy := TriggerRun(RunOption{Trigger: model.RunTrigger.API}); busy.ErrorCode != "RUN_IN_PROGRESS" {
+		t.Fatalf("second trigger: %s %s, want RUN_IN_PROGRESS", busy.Status, busy.ErrorCode)
+	}
+
+	waitIdle(t)
+	if fake.Calls("slow.stg") != 1 || fake.Calls("other.stg") != 0 {
+		t.Fatalf("calls slow=%d other=%d, want only slow.stg", fake.Calls("slow.stg"), fake.Calls("other.stg"))
+	}
+}
@@ -0,0 +1,94 @@
+package action
+
+import (
+	"example.com/micro/metrics"
+	"example.com/micro/model"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"sync"
+	"time"
+)
+
+// Counters and histograms cover the logins of this instance since it
+// started; gauges are read from the database on each scrape, so every
+// instance reports the same values.
+var (
+	loginAttempts = metrics.NewCounterVec("autologin_login_attempts_total",
+		"Account logins by environment, account type and outcome class.",
+		"environment", "type", "class")
+	loginDuration = metrics.NewHistogramVec("autologin_login_duration_seconds",
+		"Latency of account logins, retries included.",
+		metrics.DefaultBuckets, "environment")
+	lastSuccessfulRun = metrics.NewGaugeVec("autologin_last_successful_run_timestamp_seconds",
+		"End time of the last login run where every account succeeded.")
+	pausedAccounts = metrics.NewGaugeVec("autologin_paused_accounts",
+		"Accounts paused after consecutive credential failures.")
+	scheduleNextRun = metrics.NewGaugeVec("autologin_schedule_next_run_timestamp_seconds",
+		"Next planned run of each schedule topic that is not paused.", "topic")
+	scheduleLag = metrics.NewGaugeVec("autologin_schedule_lag_seconds",
+		"How long the next run of each topic that is not paused is overdue.", "topic")
+	schedulePaused = metrics.NewGaugeVec("autologin_schedule_paused",
+		"1 when the schedule topic is paused.", "topic")
+
+	// collectLock keeps concurrent scrapes from interleaving resets and sets.
+	collectLock sync.Mutex
+)
+
+// observeAttempt counts a login attempt. The class of a failed login is its
+// failure class, otherwise the attempt status.
+func observeAttempt(attempt *model.LoginAttempt) {
+	class := attempt.Status
+	if attempt.Status == model.AttemptStatus.Failed && attempt.Class != "" {
+		class = attempt.Class
+	}
+	loginAttempts.Inc(attempt.DomainType, attempt.Type, class)
+	if attempt.Status != model.AttemptStatus.ConfigError {
+		loginDuration.Observe(float64(attempt.LatencyMs)/1000, attempt.DomainType)
+	}
+}
+
+// CollectMetrics refreshes the gauges kept in the database. A gauge whose
+// source cannot be read is left out of the scrape.
+func CollectMetrics() {
+	collectLock.Lock()
+	defer collectLock.Unlock()
+	now := time.Now()
+
+	lastSuccessfulRun.Reset()
+	runs := model.DBLoginRun.Query(model.LoginRun{Status: model.RunStatus.Success}, 0, 1, &bson.M{"start_time": -1})
+	if runs.Status == common.APIStatus.Ok {
+		if run := runs.Data.([]*model.LoginRun)[0]; run.EndTime != nil {
+			lastSuccessfulRun.Set(float64(run.EndTime.Unix()))
+		}
+	}
+
+	pausedAccounts.Reset()
+	count := model.DBAccount.Count(model.Account{Status: model.AccountStatus.Paused})
+	if count.Status == common.APIStatus.Ok {
+		pausedAccounts.Set(float64(count.Total))
+	}
+
+	scheduleNextRun.Reset()
+	scheduleLag.Reset()
+	schedulePaused.Reset()
+	schedules := model.DBSchedule.Query(bson.M{}, 0, 0, &bson.M{"topic": 1})
+	if schedules.Status != common.APIStatus.Ok {
+		return
+	}
+	for _, sched := range schedules.Data.([]*model.Schedule) {
+		if isPaused(sched) {
+			schedulePaused.Set(1, sched.Topic)
+			continue
+		}
+		schedulePaused.Set(0, sched.Topic)
+		if sched.NextRun == nil {
+			continue
+		}
+		scheduleNextRun.Set(float64(sched.NextRun.Unix()), sched.Topic)
+		lag := now.Sub(*sched.NextRun).Seconds()
+		if lag < 0 {
+			lag = 0
+		}
+		scheduleLag.Set(lag, sched.Topic)
+	}
+}
@@ -0,0 +1,119 @@
+package action
+
+import (
+	"example.com/micro/config"
+	"example.com/micro/model"
+	"example.com/micro/model/core/obj"
+	"example.com/micro/notify"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// trackState records the outcome on the account and returns the change it
+// represents, or nil when the account stays in the same state. An account is
+// failing whenever its last attempt did not succeed, and is paused once its
+// consecutive credential failures reach config.PauseThreshold.
+func trackState(account *model.Account, attempt *model.LoginAttempt) *notify.Change {
+	wasFailing := account.LastStatus != "" && account.LastStatus != model.AttemptStatus.Success
+	isFailing := attempt.Status != model.AttemptStatus.Success
+	failures := countFailures(account, attempt)
+	paused := config.PauseThreshold > 0 && failures >= config.PauseThreshold &&
+		account.Status == model.AccountStatus.Active
+
+	updater := model.Account{
+		LastStatus:          attempt.Status,
+		LastAttemptTime:     attempt.StartTime,
+		ConsecutiveFailures: obj.WithInt(failures),
+	}
+	if paused {
+		updater.Status = model.AccountStatus.Paused
+		updater.PausedTime = attempt.StartTime
+		updater.PauseReason = fmt.Sprintf("%d consecutive failures, last %s (%d %s)",
+			failures, attempt.Class, attempt.HTTPCode, attempt.ErrorCode)
+		account.Status = updater.Status
+		account.PausedTime = updater.PausedTime
+		account.PauseReason = updater.PauseReason
+	}
+	if account.ID != nil {
+		model.DBAccount.UpdateOne(bson.M{"_id": account.ID}, updater)
+	}
+	account.LastStatus = attempt.Status
+	account.LastAttemptTime = attempt.StartTime
+	account.ConsecutiveFailures = updater.ConsecutiveFailures
+	attempt.Paused = paused
+
+	var state string
+	switch {
+	case paused:
+		state = notify.StatePaused
+	case isFailing && !wasFailing:
+		state = notify.StateFailing
+	case !isFailing && wasFailing:
+		state = notify.StateRecovered
+	default:
+		return nil
+	}
+	attempt.Change = state
+
+	return &notify.Change{
+		Username:   account.Username,
+		DomainType: account.DomainType,
+		Type:       account.Type,
+		Owner:      account.Owner,
+		State:      state,
+		HTTPCode:   attempt.HTTPCode,
+		ErrorCode:  attempt.ErrorCode,
+		Message:    attempt.Message,
+		Failures:   failures,
+	}
+}
+
+// countFailures returns the consecutive credential failures of the account
+// after attempt. Only wrong credentials and lockouts count, as they are what
+// trips the auth service's lockout policy; outages neither count nor reset.
+func countFailures(account *model.Account, attempt *model.LoginAttempt) int {
+	previous := 0
+	if account.ConsecutiveFailures != nil {
+		previous = *account.ConsecutiveFailures
+	}
+	switch {
+	case attempt.Status == model.AttemptStatus.Success || attempt.Status == model.AttemptStatus.VerifyFailed:
+		return 0
+	case attempt.Status == model.AttemptStatus.Failed &&
+		(attempt.Class == model.FailureClass.BadCredentials || attempt.Class == model.FailureClass.Locked):
+		return previous + 1
+	}
+	return previous
+}
+
+// notifyRun posts the run summary to the webhooks when accounts changed state.
+func notifyRun(run *model.LoginRun, changes []*notify.Change) {
+	summary := &notify.Summary{
+		RunID:       run.RunID,
+		Trigger:     run.Trigger,
+		Environment: run.Environment,
+		Status:      run.Status,
+		Total:       *run.Total,
+		Success:     *run.Success,
+		Fail:        *run.Fail,
+		VerifyFail:  *run.VerifyFail,
+		ConfigError: *run.ConfigError,
+	}
+	for _, change := range changes {
+		switch change.State {
+		case notify.StateRecovered:
+			summary.Recovered = append(summary.Recovered, change)
+		case notify.StatePaused:
+			summary.Paused = append(summary.Paused, change)
+		default:
+			summary.Failing = append(summary.Failing, change)
+		}
+	}
+	if len(config.Webhooks) == 0 || !summary.HasChanges() {
+		return
+	}
+
+	if err := notify.Send(config.Webhooks, summary); err != nil {
+		fmt.Println("Error when sending notifications: ", err)
+	}
+}
@@ -0,0 +1,48 @@
+package action
+
+import (
+	"example.com/micro/client/login"
+	"example.com/micro/config"
+	"example.com/micro/fakeauth"
+	"example.com/micro/model"
+	"net/http"
+	"testing"
+)
+
+func TestAutoLoginPausesBeforeFakeLockout(t *testing.T) {
+	threshold := config.PauseThreshold
+	config.PauseThreshold = 2
+	t.Cleanup(func() { config.PauseThreshold = threshold })
+
+	fake := setupFakeAuth(t, fakeauth.Config{
+		LockoutThreshold: 3,
+		Users: []*fakeauth.User{
+			{Username: "changed.stg", Password: "new-password"},
+			{Username: "flaky.stg", Password: "secret", FailCode: http.StatusBadGateway},
+		},
+	},
+		account("stg", "changed.stg", "old-password"),
+		account("stg", "flaky.stg", "secret"),
+	)
+
+	var paused int
+	for i := 0; i < 5; i++ {
+		run, err := RunAutoLogin(RunOption{Trigger: model.RunTrigger.API})
+		if err != nil {
+			t.Fatalf("RunAutoLogin: %v", err)
+		}
+		paused += *run.Paused
+	}
+	if fake.Locked("changed.stg") {
+		t.Fatal("account locked by the auth service despite the pause")
+	}
+	if got := fake.Calls("changed.stg"); got != 2 {
+		t.Fatalf("account called %d times, want 2 then paused", got)
+	}
+	if paused != 1 {
+		t.Fatalf("%d pause(s) recorded, want 1", paused)
+	}
+	if got := fake.Calls("flaky.stg"); got != 5*(login.Retry.MaxRetries+1) {
+		t.Errorf("flaky account called %d times, want every run (server errors never pause)", got)
+	}
+}
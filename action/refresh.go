@@ -0,0 +1,158 @@
+package action
+
+import (
+	"errors"
+	"example.com/micro/config"
+	"example.com/micro/model"
+	"fmt"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/common"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/schedule"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+const (
+	// refreshRetryDelay postpones the refresh of an account whose re-login
+	// failed, so a broken account is not retried on every scheduler tick.
+	refreshRetryDelay = time.Hour
+	// refreshIdleDelay is when the refresh topic runs again if no session
+	// is waiting for a refresh.
+	refreshIdleDelay = time.Hour
+)
+
+func sessionKey(domainType, username string) string {
+	return domainType + "__" + username
+}
+
+// refreshTimes maps every account holding a session to when it is due for
+// a refresh.
+func refreshTimes() (map[string]time.Time, error) {
+	times := make(map[string]time.Time)
+	for offset := int64(0); ; offset += accountPageSize {
+		resp := model.DBSessionToken.Query(bson.M{
+			"refresh_time": bson.M{"$exists": true},
+		}, offset, accountPageSize, &bson.M{"_id": 1})
+		if resp.Status == common.APIStatus.NotFound {
+			break
+		}
+		if resp.Status != common.APIStatus.Ok {
+			return nil, fmt.Errorf("load session tokens: %s", resp.Message)
+		}
+
+		page := resp.Data.([]*model.SessionToken)
+		for _, token := range page {
+			times[sessionKey(token.DomainType, token.Username)] = *token.RefreshTime
+		}
+		if len(page) < accountPageSize {
+			break
+		}
+	}
+	return times, nil
+}
+
+// selectBySession narrows the accounts of a run by their session. Scheduled
+// runs skip accounts whose session is not due for a refresh yet; refresh runs
+// keep only the accounts that are due. Other runs log every account in. The
+// second result is the number of accounts left out.
+func selectBySession(trigger string, accounts []*model.Account, now time.Time) ([]*model.Account, int) {
+	if trigger != model.RunTrigger.Schedule && trigger != model.RunTrigger.Refresh {
+		return accounts, 0
+	}
+	times, err := refreshTimes()
+	if err != nil {
+		fmt.Println("Cannot load session refresh times: ", err)
+		if trigger == model.RunTrigger.Refresh {
+			return nil, len(accounts)
+		}
+		return accounts, 0
+	}
+
+	selected := make([]*model.Account, 0, len(accounts))
+	for _, account := range accounts {
+		refreshTime, ok := times[sessionKey(account.DomainType, account.Username)]
+		due := !ok || !refreshTime.After(now)
+		if trigger == model.RunTrigger.Refresh {
+			due = ok && !refreshTime.After(now)
+		}
+		if due {
+			selected = append(selected, account)
+		}
+	}
+	return selected, len(accounts) - len(selected)
+}
+
+// postponeFailedRefreshes moves the refresh of accounts that could not log in
+// back by refreshRetryDelay.
+func postponeFailedRefreshes(attempts []*model.LoginAttempt) {
+	next := time.Now().Add(refreshRetryDelay)
+	for _, attempt := range attempts {
+		if attempt.Status == model.AttemptStatus.Success {
+			continue
+		}
+		model.DBSessionToken.UpdateOne(model.SessionToken{
+			Username:   attempt.Username,
+			DomainType: attempt.DomainType,
+		}, model.SessionToken{RefreshTime: &next})
+	}
+}
+
+// RefreshTokens logs in again the accounts whose session is about to expire,
+// then plans the next run at the earliest upcoming refresh. Only active
+// accounts count: the session of a disabled, paused or deleted account stays
+// due but is never refreshed. A refresh that finds a run in flight is
+// skipped and checked again after RUN_LOCK_TTL.
+func RefreshTokens(timeNew *time.Time, scheduleConfig *schedule.Config) (error, string, *time.Time) {
+	now := time.Now()
+	due, upcoming, err := refreshesOfActiveAccounts(now)
+	if err != nil {
+		return err, "", nil
+	}
+
+	note := "no session to refresh"
+	if due > 0 {
+		run, err := RunAutoLogin(RunOption{Trigger: model.RunTrigger.Refresh})
+		// The sessions stay due: check them again once the run in
+		// flight, here or on another instance, is likely over.
+		if errors.Is(err, ErrRunInProgress) {
+			next := now.Add(config.RunLockTTL)
+			return nil, "skipped: " + err.Error(), &next
+		}
+		if err != nil {
+			return err, "", nil
+		}
+		note = fmt.Sprintf("run %s %s", run.RunID, run.Status)
+	}
+
+	next := now.Add(refreshIdleDelay)
+	if upcoming != nil && upcoming.Before(next) {
+		next = *upcoming
+	}
+	return nil, note, &next
+}
+
+// refreshesOfActiveAccounts counts the active accounts whose session is due
+// for a refresh at now, and returns the earliest refresh after now.
+func refreshesOfActiveAccounts(now time.Time) (int, *time.Time, error) {
+	accounts, err := accountSource.ActiveAccounts("")
+	if err != nil {
+		return 0, nil, fmt.Errorf("load accounts: %w", err)
+	}
+	times, err := refreshTimes()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	due := 0
+	var upcoming *time.Time
+	for _, account := range accounts {
+		refreshTime, ok := times[sessionKey(account.DomainType, account.Username)]
+		switch {
+		case !ok:
+		case !refreshTime.After(now):
+			due++
+		case upcoming == nil || refreshTime.Before(*upcoming):
+			upcoming = &refreshTime
+		}
+	}
+	return due, upcoming, nil
+}
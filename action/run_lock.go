@@ -0,0 +1,336 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"example.com/micro/config"
+	"example.com/micro/model"
+	"fmt"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// ErrLockLost means another instance took the run lock over, usually after
+// this one missed its heartbeats.
+var ErrLockLost = errors.New("run lock lost to another instance")
+
+// LeaderLock elects the one instance of the service that executes login
+// runs, so that scaling out does not log every account in several times.
+type LeaderLock interface {
+	// Acquire takes the lock for a new run, or for another change that must
+	// not happen during a run. It fails with ErrRunInProgress while another
+	// instance holds it or an abandoned run awaits takeover.
+	Acquire(runID string) (*LockGrant, error)
+	// Takeover takes the lock of a holder that died mid-run and returns the
+	// grant for its run, or nil when no run was abandoned.
+	Takeover() (*LockGrant, error)
+	// Renew extends the lock. It fails with ErrLockLost once another
+	// instance holds it, and with another error when it could not tell.
+	Renew(grant *LockGrant) error
+	// Release frees the lock once the run is recorded.
+	Release(grant *LockGrant) error
+}
+
+// LockGrant is a held run lock: its holder, fencing token and run.
+type LockGrant struct {
+	Holder string
+	Token  int64
+	RunID  string
+}
+
+// instanceID names this process as lock holder. Cloud Run instances may
+// share a hostname, so it carries a unique suffix.
+var instanceID = func() string {
+	hostname, _ := os.Hostname()
+	return hostname + "/" + primitive.NewObjectID().Hex()
+}()
+
+// MongoLeaderLock keeps the lock in the run_lock collection. Every change
+// is a single findOneAndUpdate guarded by the expiry or the fencing token.
+type MongoLeaderLock struct{}
+
+func (MongoLeaderLock) Acquire(runID string) (*LockGrant, error) {
+	now := time.Now()
+	set := lockRenewal(now)
+	set["run_id"] = runID
+	// A missing lock document is created by the upsert; an existing one that
+	// does not match makes the upsert fail on the unique name.
+	resp := model.DBRunLock.UpdateOneWithOption(bson.M{
+		"name":         model.LoginRunLock,
+		"run_id":       bson.M{"$exists": false},
+		"expired_time": bson.M{"$lte": now},
+	}, bson.M{
+		"$set":         set,
+		"$inc":         bson.M{"token": 1},
+		"$setOnInsert": bson.M{"created_time": now},
+	}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After))
+	// The SDK reports any failure of the update as NotFound: the lock is
+	// only busy when the document says so.
+	if resp.Status != common.APIStatus.Ok {
+		lock, err := currentLock()
+		if err != nil {
+			return nil, fmt.Errorf("acquire run lock: %s (%v)", resp.Message, err)
+		}
+		if lock != nil && (lock.RunID != "" || lock.ExpiredTime != nil && lock.ExpiredTime.After(now)) {
+			return nil, fmt.Errorf("%w: run %s on %s", ErrRunInProgress, lock.RunID, lock.Holder)
+		}
+		return nil, errors.New("acquire run lock: " + resp.Message)
+	}
+	lock := resp.Data.([]*model.RunLock)[0]
+	return &LockGrant{Holder: instanceID, Token: lock.Token, RunID: runID}, nil
+}
+
+func (MongoLeaderLock) Takeover() (*LockGrant, error) {
+	now := time.Now()
+	resp := model.DBRunLock.UpdateOneWithOption(bson.M{
+		"name":         model.LoginRunLock,
+		"run_id":       bson.M{"$exists": true},
+		"expired_time": bson.M{"$lte": now},
+	}, bson.M{
+		"$set": lockRenewal(now),
+		"$inc": bson.M{"token": 1},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if resp.Status == common.APIStatus.NotFound {
+		return nil, nil
+	}
+	if resp.Status != common.APIStatus.Ok {
+		return nil, errors.New(resp.Message)
+	}
+	lock := resp.Data.([]*model.RunLock)[0]
+	return &LockGrant{Holder: instanceID, Token: lock.Token, RunID: lock.RunID}, nil
+}
+
+func (MongoLeaderLock) Renew(grant *LockGrant) error {
+	resp := model.DBRunLock.UpdateOneWithOption(bson.M{
+		"name":  model.LoginRunLock,
+		"token": grant.Token,
+	}, bson.M{"$set": lockRenewal(time.Now())})
+	if resp.Status != common.APIStatus.Ok {
+		return lockFailure(grant, "renew", resp.Message)
+	}
+	return nil
+}
+
+func (MongoLeaderLock) Release(grant *LockGrant) error {
+	now := time.Now()
+	resp := model.DBRunLock.UpdateOneWithOption(bson.M{
+		"name":  model.LoginRunLock,
+		"token": grant.Token,
+	}, bson.M{
+		"$set":   bson.M{"expired_time": now, "last_updated_time": now},
+		"$unset": bson.M{"run_id": ""},
+	})
+	if resp.Status != common.APIStatus.Ok {
+		return lockFailure(grant, "release", resp.Message)
+	}
+	return nil
+}
+
+// lockFailure tells why an update fenced by the token of grant failed. The
+// SDK reports any failure as NotFound, so the lock is only lost when the
+// document carries another token.
+func lockFailure(grant *LockGrant, action, message string) error {
+	lock, err := currentLock()
+	if err == nil && (lock == nil || lock.Token != grant.Token) {
+		return ErrLockLost
+	}
+	if err != nil {
+		return fmt.Errorf("%s run lock: %s (%v)", action, message, err)
+	}
+	return fmt.Errorf("%s run lock: %s", action, message)
+}
+
+// currentLock reads the run lock document, nil when there is none yet.
+func currentLock() (*model.RunLock, error) {
+	resp := model.DBRunLock.QueryOne(model.RunLock{Name: model.LoginRunLock})
+	if resp.Status == common.APIStatus.NotFound {
+		return nil, nil
+	}
+	if resp.Status != common.APIStatus.Ok {
+		return nil, errors.New(resp.Message)
+	}
+	return resp.Data.([]*model.RunLock)[0], nil
+}
+
+func lockRenewal(now time.Time) bson.M {
+	return bson.M{
+		"holder":            instanceID,
+		"heartbeat_time":    now,
+		"expired_time":      now.Add(config.RunLockTTL),
+		"last_updated_time": now,
+	}
+}
+
+var leaderLock LeaderLock = MongoLeaderLock{}
+
+// SetLeaderLock replaces how instances agree on who executes login runs.
+func SetLeaderLock(lock LeaderLock) {
+	leaderLock = lock
+}
+
+// heartbeat renews a run lock every third of its TTL until stopped.
+type heartbeat struct {
+	grant *LockGrant
+	lost  atomic.Bool
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func keepLock(grant *LockGrant) *heartbeat {
+	hb := &heartbeat{grant: grant, stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(hb.done)
+		tick := time.NewTicker(config.RunLockTTL / 3)
+		defer tick.Stop()
+		for {
+			select {
+			case <-hb.stop:
+				return
+			case <-tick.C:
+			}
+			err := leaderLock.Renew(grant)
+			if errors.Is(err, ErrLockLost) {
+				hb.lost.Store(true)
+				fmt.Printf("Run %s: %v\n", grant.RunID, err)
+				return
+			}
+			if err != nil {
+				// The lock still carries the grant's token, or could not
+				// be read: the renewal is retried at the next tick.
+				fmt.Printf("Run %s: cannot renew the run lock: %v\n", grant.RunID, err)
+			}
+		}
+	}()
+	return hb
+}
+
+// Lost reports whether another instance took the lock over.
+func (hb *heartbeat) Lost() bool {
+	return hb.lost.Load()
+}
+
+// release stops the heartbeat and frees the lock, unless it was lost.
+func (hb *heartbeat) release() {
+	close(hb.stop)
+	<-hb.done
+	if hb.Lost() {
+		return
+	}
+	if err := leaderLock.Release(hb.grant); err != nil {
+		fmt.Printf("Run %s: cannot release the run lock: %v\n", hb.grant.RunID, err)
+	}
+}
+
+// ResumeAbandonedRun takes over the lock of an instance that died mid-run
+// and logs in the accounts its run had not attempted yet. It does nothing
+// while this instance runs or stops.
+func ResumeAbandonedRun() error {
+	if !runLock.TryLock() {
+		return nil
+	}
+	defer runLock.Unlock()
+
+	grant, err := leaderLock.Takeover()
+	if err != nil || grant == nil {
+		return err
+	}
+
+	// The holder may have died between taking the lock and recording its
+	// run, which leaves nothing to resume.
+	resp := model.DBLoginRun.QueryOne(model.LoginRun{RunID: grant.RunID})
+	if resp.Status == common.APIStatus.NotFound {
+		return leaderLock.Release(grant)
+	}
+	// On other errors the lock is kept until it expires and taken over again.
+	if resp.Status != common.APIStatus.Ok {
+		return fmt.Errorf("abandoned run %s: %s", grant.RunID, resp.Message)
+	}
+	run := resp.Data.([]*model.LoginRun)[0]
+	if run.Status != model.RunStatus.Running {
+		return leaderLock.Release(grant)
+	}
+
+	done, err := getRunAttempts(run.RunID)
+	if err != nil {
+		return fmt.Errorf("abandoned run %s: %w", run.RunID, err)
+	}
+	fmt.Printf("Resuming run %s abandoned by %s, %d account(s) already attempted\n", run.RunID, run.Hostname, len(done))
+
+	hostname, _ := os.Hostname()
+	run.Hostname = hostname
+	run.LockToken = grant.Token
+	run.Resumed = true
+	model.DBLoginRun.UpdateOne(bson.M{"run_id": run.RunID}, model.LoginRun{
+		Hostname:  run.Hostname,
+		LockToken: run.LockToken,
+		Resumed:   true,
+	})
+	executeRun(run, RunOption{
+		Trigger:     run.Trigger,
+		Environment: run.Environment,
+		Username:    run.Username,
+		Type:        run.AccountType,
+	}, grant, done)
+	return nil
+}
+
+// WatchRunLock looks for an abandoned run every RUN_LOCK_TTL and resumes
+// it, until ctx is done.
+func WatchRunLock(ctx context.Context) {
+	tick := time.NewTicker(config.RunLockTTL)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		if err := ResumeAbandonedRun(); err != nil {
+			fmt.Println("Cannot resume abandoned run: ", err)
+		}
+	}
+}
+
+// getRunAttempts pages through the attempts recorded for a run.
+func getRunAttempts(runID string) ([]*model.LoginAttempt, error) {
+	var attempts []*model.LoginAttempt
+	for offset := int64(0); ; offset += accountPageSize {
+		resp := model.DBLoginAttempt.Query(model.LoginAttempt{RunID: runID}, offset, accountPageSize, &bson.M{"_id": 1})
+		if resp.Status == common.APIStatus.NotFound {
+			break
+		}
+		if resp.Status != common.APIStatus.Ok {
+			return nil, errors.New(resp.Message)
+		}
+
+		page := resp.Data.([]*model.LoginAttempt)
+		attempts = append(attempts, page...)
+		if len(page) < accountPageSize {
+			break
+		}
+	}
+	return attempts, nil
+}
+
+// skipAttempted leaves out the accounts a resumed run already attempted.
+func skipAttempted(accounts []*model.Account, done []*model.LoginAttempt) []*model.Account {
+	if len(done) == 0 {
+		return accounts
+	}
+	attempted := make(map[string]bool, len(done))
+	for _, attempt := range done {
+		attempted[sessionKey(attempt.DomainType, attempt.Username)] = true
+	}
+	remaining := make([]*model.Account, 0, len(accounts))
+	for _, account := range accounts {
+		if !attempted[sessionKey(account.DomainType, account.Username)] {
+			remaining = append(remaining, account)
+		}
+	}
+	return remaining
+}
@@ -0,0 +1,75 @@
+package action
+
+import (
+	"errors"
+	"example.com/micro/config"
+	"example.com/micro/fakeauth"
+	"example.com/micro/model"
+	"testing"
+	"time"
+)
+
+func TestAutoLoginWaitsForRunLock(t *testing.T) {
+	fake := setupFakeAuth(t, fakeauth.Config{
+		Users: []*fakeauth.User{{Username: "alice.stg", Password: "secret"}},
+	}, account("stg", "alice.stg", "secret"))
+	lock := &memoryLock{}
+	SetLeaderLock(lock)
+
+	other, err := lock.Acquire("other-instance-run")
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if _, err := RunAutoLogin(RunOption{Trigger: model.RunTrigger.API}); !errors.Is(err, ErrRunInProgress) {
+		t.Fatalf("RunAutoLogin while another instance runs: %v, want ErrRunInProgress", err)
+	}
+	if fake.Calls("alice.stg") != 0 {
+		t.Fatalf("calls = %d while another instance runs, want 0", fake.Calls("alice.stg"))
+	}
+
+	if err := lock.Release(other); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	run, err := RunAutoLogin(RunOption{Trigger: model.RunTrigger.API})
+	if err != nil {
+		t.Fatalf("RunAutoLogin: %v", err)
+	}
+	if run.Status != model.RunStatus.Success || run.LockToken != 2 {
+		t.Fatalf("run %s with token %d, want SUCCESS with token 2", run.Status, run.LockToken)
+	}
+	if lock.runID != "" {
+		t.Fatalf("run lock still held for %s after the run", lock.runID)
+	}
+}
+
+func TestAutoLoginStopsWhenRunLockLost(t *testing.T) {
+	ttl, concurrency := config.RunLockTTL, config.LoginConcurrency
+	config.RunLockTTL, config.LoginConcurrency = 30*time.Millisecond, 1
+	t.Cleanup(func() { config.RunLockTTL, config.LoginConcurrency = ttl, concurrency })
+
+	fake := setupFakeAuth(t, fakeauth.Config{
+		Users: []*fakeauth.User{
+			{Username: "first.stg", Password: "secret", LatencyMs: 100},
+			{Username: "second.stg", Password: "secret"},
+		},
+	},
+		account("stg", "first.stg", "secret"),
+		account("stg", "second.stg", "secret"),
+	)
+	lock := &memoryLock{}
+	SetLeaderLock(lock)
+
+	// The lock goes to another instance while the first login is in flight.
+	time.AfterFunc(20*time.Millisecond, lock.steal)
+	run, err := RunAutoLogin(RunOption{Trigger: model.RunTrigger.API})
+	if err != nil {
+		t.Fatalf("RunAutoLogin: %v", err)
+	}
+	if run.Status != model.RunStatus.Running {
+		t.Fatalf("run %s, want it left RUNNING for the new holder", run.Status)
+	}
+	if fake.Calls("first.stg") != 1 || fake.Calls("second.stg") != 0 {
+		t.Fatalf("calls first=%d second=%d, want the run stopped after first.stg",
+			fake.Calls("first.stg"), fake.Calls("second.stg"))
+	}
+}
@@ -1,26 +1,197 @@
 package action
 
 import (
+	"errors"
+	"example.com/micro/config"
+	"example.com/micro/cron"
 	"example.com/micro/model"
+	"example.com/micro/model/core/obj"
 	"fmt"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/common"
 	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/schedule"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"time"
 )
 
+// AutoLogin runs the login job and computes the next run from the topic's
+// cron expression. The SDK scheduler picks up any config whose next_run is in
+// the past, so runs missed during downtime are caught up once. An occurrence
+// that finds a run in flight is skipped rather than failed.
 func AutoLogin(timeNew *time.Time, scheduleConfig *schedule.Config) (error, string, *time.Time) {
-	now := time.Now().UTC()
-	nextTime := now.Add(24 * time.Hour)
-	nextTime = time.Date(nextTime.Year(), nextTime.Month(), nextTime.Day(), 17, 0, 0, 0, time.UTC)
+	spec, err := getScheduleSpec(scheduleConfig.Topic)
+	if err != nil {
+		return err, "", nil
+	}
+
+	nextTime := spec.Next(time.Now())
+	if nextTime.IsZero() {
+		return errors.New("cron expression " + spec.String() + " never fires"), "", nil
+	}
+
 	fmt.Println("Schedule running!")
-	return nil, "Successfully!", &nextTime
+	run, err := RunAutoLogin(RunOption{Trigger: model.RunTrigger.Schedule})
+	// Another instance, or a run triggered here, covers this occurrence.
+	if errors.Is(err, ErrRunInProgress) {
+		return nil, "skipped: " + err.Error(), &nextTime
+	}
+	if err != nil {
+		return err, "", nil
+	}
+	return nil, fmt.Sprintf("run %s %s", run.RunID, run.Status), &nextTime
+}
+
+func getScheduleSpec(topic string) (*cron.Schedule, error) {
+	resp := model.DBSchedule.QueryOne(model.Schedule{Topic: topic})
+	if resp.Status != common.APIStatus.Ok {
+		return nil, errors.New("schedule " + topic + " not found: " + resp.Message)
+	}
+	sched := resp.Data.([]*model.Schedule)[0]
+	if sched.Cron == "" {
+		return cron.Parse(config.AutoLoginCron, config.AutoLoginTimeZone)
+	}
+	return cron.Parse(sched.Cron, sched.TimeZone)
+}
+
+func scheduleNotFound(topic string) *common.APIResponse {
+	return &common.APIResponse{
+		Status:    common.APIStatus.NotFound,
+		Message:   "Schedule " + topic + " not found",
+		ErrorCode: "SCHEDULE_NOT_FOUND",
+	}
+}
+
+// UpdateSchedule changes the cron expression and time zone of a topic and
+// reschedules its next run accordingly. A paused topic stays paused.
+func UpdateSchedule(topic string, input *model.Schedule) *common.APIResponse {
+	spec, err := cron.Parse(input.Cron, input.TimeZone)
+	if err != nil {
+		return obj.WithInvalidInput(err)
+	}
+	nextRun := spec.Next(time.Now())
+	if nextRun.IsZero() {
+		return obj.WithInvalidInput(errors.New("cron expression never fires"))
+	}
+
+	existed := model.DBSchedule.QueryOne(model.Schedule{Topic: topic})
+	if existed.Status != common.APIStatus.Ok {
+		return scheduleNotFound(topic)
+	}
+	updater := model.Schedule{
+		Cron:     input.Cron,
+		TimeZone: input.TimeZone,
+		NextRun:  &nextRun,
+	}
+	if isPaused(existed.Data.([]*model.Schedule)[0]) {
+		updater.NextRun = nil
+	}
+	return model.DBSchedule.UpdateOne(model.Schedule{Topic: topic}, updater)
+}
+
+// pausedNextRun parks a paused topic: the SDK scheduler only picks topics
+// whose next run is past.
+var pausedNextRun = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+func isPaused(sched *model.Schedule) bool {
+	return sched.Paused != nil && *sched.Paused
+}
+
+func topicPaused(topic string) bool {
+	resp := model.DBSchedule.QueryOne(model.Schedule{Topic: topic})
+	return resp.Status == common.APIStatus.Ok && isPaused(resp.Data.([]*model.Schedule)[0])
+}
+
+// pausable wraps a processor so that a topic paused while the scheduler
+// picked it up does not run, and one paused during its run stays parked
+// whatever the run returns.
+func pausable(process schedule.Process) schedule.Process {
+	return func(timeNew *time.Time, scheduleConfig *schedule.Config) (error, string, *time.Time) {
+		if topicPaused(scheduleConfig.Topic) {
+			return nil, "paused", &pausedNextRun
+		}
+		err, note, nextRun := process(timeNew, scheduleConfig)
+		if topicPaused(scheduleConfig.Topic) {
+			if err != nil {
+				note = "failed before pause: " + err.Error()
+			}
+			return nil, note, &pausedNextRun
+		}
+		return err, note, nextRun
+	}
+}
+
+// GetScheduleList returns every schedule topic with the result of its last
+// run from the scheduler history.
+func GetScheduleList() *common.APIResponse {
+	resp := model.DBSchedule.Query(bson.M{}, 0, 0, &bson.M{"topic": 1})
+	if resp.Status != common.APIStatus.Ok {
+		return resp
+	}
+	for _, sched := range resp.Data.([]*model.Schedule) {
+		history := ScheduleDb.GetHistoryDB().Query(schedule.History{Topic: sched.Topic}, 0, 1, &bson.M{"_id": -1})
+		if history.Status == common.APIStatus.Ok {
+			sched.LastResult = history.Data.([]*schedule.History)[0].Result
+		}
+	}
+	return resp
+}
+
+// PauseSchedule stops a topic from running until it is resumed.
+func PauseSchedule(topic string) *common.APIResponse {
+	resp := model.DBSchedule.UpdateOne(model.Schedule{Topic: topic}, model.Schedule{
+		Paused:  obj.WithBool(true),
+		NextRun: &pausedNextRun,
+	})
+	if resp.Status == common.APIStatus.NotFound {
+		return scheduleNotFound(topic)
+	}
+	return resp
+}
+
+// ResumeSchedule plans the next run of a paused topic from its cron
+// expression, or right away for topics without one.
+func ResumeSchedule(topic string) *common.APIResponse {
+	existed := model.DBSchedule.QueryOne(model.Schedule{Topic: topic})
+	if existed.Status != common.APIStatus.Ok {
+		return scheduleNotFound(topic)
+	}
+	sched := existed.Data.([]*model.Schedule)[0]
+
+	nextRun := time.Now()
+	if sched.Cron != "" {
+		spec, err := cron.Parse(sched.Cron, sched.TimeZone)
+		if err != nil {
+			return obj.WithInvalidInput(err)
+		}
+		nextRun = spec.Next(nextRun)
+	}
+	return model.DBSchedule.UpdateOne(model.Schedule{Topic: topic}, model.Schedule{
+		Paused:  obj.WithBool(false),
+		NextRun: &nextRun,
+	})
 }
 
 var processor = make(map[string]schedule.Process)
 var ScheduleDb = schedule.NewConfigDB("schedule_auto_login", processor)
 
-func InitScheduleAutoLogin(database *mongo.Database) {
+func InitScheduleAutoLogin(database *mongo.Database) error {
+	spec, err := cron.Parse(config.AutoLoginCron, config.AutoLoginTimeZone)
+	if err != nil {
+		return err
+	}
 	model.InitSchedule(database)
-	processor[model.AutoLogin] = AutoLogin
+	model.EnsureSchedule(&model.Schedule{
+		Topic:    model.AutoLogin,
+		NextRun:  obj.WithTime(spec.Next(time.Now())),
+		Cron:     config.AutoLoginCron,
+		TimeZone: config.AutoLoginTimeZone,
+	})
+	model.EnsureSchedule(&model.Schedule{
+		Topic:   model.TokenRefresh,
+		NextRun: obj.WithTime(),
+	})
+	processor[model.AutoLogin] = pausable(AutoLogin)
+	processor[model.TokenRefresh] = pausable(RefreshTokens)
 	ScheduleDb.Init(database)
+	return nil
 }
@@ -0,0 +1,229 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"example.com/micro/model"
+	"example.com/micro/secret"
+	"fmt"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ImportAccountSeed validates a JSON seed file and loads its accounts into
+// an empty registry. A seed with any invalid account is refused, even once
+// the registry holds accounts and the seed is no longer imported.
+func ImportAccountSeed(path string) error {
+	seed, invalid, err := ValidateAccountSeed(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if len(invalid) > 0 {
+		return &SeedError{Path: path, Invalid: invalid}
+	}
+
+	count := model.DBAccount.Count(bson.M{})
+	if count.Status != common.APIStatus.Ok {
+		return errors.New(count.Message)
+	}
+	if count.Total > 0 {
+		return nil
+	}
+
+	imported := 0
+	for _, account := range seed {
+		resp := CreateAccount(account)
+		if resp.Status != common.APIStatus.Ok {
+			fmt.Printf("Skip seed account %s (%s): %s\n", account.Username, account.DomainType, resp.Message)
+			continue
+		}
+		imported++
+	}
+	fmt.Printf("Imported %d/%d seed account(s) from %s\n", imported, len(seed), path)
+	return nil
+}
+
+// SeedError lists the problems of a seed file.
+type SeedError struct {
+	Path    string
+	Invalid []error
+}
+
+func (e *SeedError) Error() string {
+	lines := make([]string, 0, len(e.Invalid)+1)
+	lines = append(lines, fmt.Sprintf("%d problem(s) in %s:", len(e.Invalid), e.Path))
+	for _, err := range e.Invalid {
+		lines = append(lines, "  "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ValidateAccountSeed reads a seed file strictly and checks every account
+// as an import would, without a database: unknown fields, required fields,
+// account types and environments, plaintext secrets, and usernames repeated
+// within an environment. It returns the accounts and one error per invalid
+// field, as "accounts[3].type: ...", or err when the file cannot be read or
+// is not a JSON array.
+func ValidateAccountSeed(path string) (seed []*model.Account, invalid []error, err error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	seed, invalid, err = validateAccounts(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return seed, invalid, nil
+}
+
+func validateAccounts(content []byte) (seed []*model.Account, invalid []error, err error) {
+	var raw []json.RawMessage
+	if err = json.Unmarshal(content, &raw); err != nil {
+		return nil, nil, err
+	}
+
+	problem := func(i int, field string, err error) {
+		if field != "" {
+			field = "." + field
+		}
+		invalid = append(invalid, fmt.Errorf("accounts[%d]%s: %w", i, field, err))
+	}
+	first := make(map[string]int, len(raw))
+	for i, item := range raw {
+		account, err := decodeAccount(item)
+		var fieldErr *FieldError
+		if errors.As(err, &fieldErr) {
+			problem(i, fieldErr.Field, fieldErr.Err)
+			continue
+		}
+		if err != nil {
+			problem(i, "", err)
+			continue
+		}
+		seed = append(seed, account)
+
+		for _, p := range accountProblems(account, false) {
+			problem(i, p.Field, p.Err)
+		}
+		if err := secret.CheckStored(account.Password); err != nil {
+			problem(i, "password", err)
+		}
+		if err := secret.CheckStored(account.TOTPSeed); err != nil {
+			problem(i, "totpSeed", err)
+		}
+		if account.Username == "" || account.DomainType == "" {
+			continue
+		}
+		key := sessionKey(account.DomainType, account.Username)
+		if j, ok := first[key]; ok {
+			problem(i, "username", fmt.Errorf("%q is already accounts[%d] in %s", account.Username, j, account.DomainType))
+			continue
+		}
+		first[key] = i
+	}
+	return seed, invalid, nil
+}
+
+// decodeAccount decodes one seed account strictly: every key must be the
+// exact name of an Account field, where encoding/json alone ignores unknown
+// keys and matches the others regardless of case.
+func decodeAccount(item json.RawMessage) (*model.Account, error) {
+	if string(item) == "null" {
+		return nil, errors.New("null account")
+	}
+	if err := checkFieldNames(item, reflect.TypeOf(model.Account{}), ""); err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(item))
+	decoder.DisallowUnknownFields()
+	var account model.Account
+	if err := decoder.Decode(&account); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return nil, &FieldError{Field: typeErr.Field, Err: fmt.Errorf("%s where %s is expected", typeErr.Value, typeErr.Type)}
+		}
+		return nil, errors.New(strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return &account, nil
+}
+
+// checkFieldNames walks a JSON value along type t and returns a FieldError
+// for the first object key that is not the JSON name of a field. Values that
+// do not match t are left for the decoder to report.
+func checkFieldNames(raw json.RawMessage, t reflect.Type, path string) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice:
+		var items []json.RawMessage
+		if json.Unmarshal(raw, &items) != nil {
+			return nil
+		}
+		for i, item := range items {
+			if err := checkFieldNames(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		var object map[string]json.RawMessage
+		if json.Unmarshal(raw, &object) != nil {
+			return nil
+		}
+		fields := jsonFields(t)
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			name := key
+			if path != "" {
+				name = path + "." + key
+			}
+			field, ok := fields[key]
+			if !ok {
+				return &FieldError{Field: name, Err: unknownField(key, fields)}
+			}
+			if err := checkFieldNames(object[key], field, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jsonFields maps the JSON names of the fields of struct t to their type.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+func unknownField(key string, fields map[string]reflect.Type) error {
+	for name := range fields {
+		if strings.EqualFold(name, key) {
+			return fmt.Errorf("unknown field, did you mean %q?", name)
+		}
+	}
+	return errors.New("unknown field")
+}
@@ -0,0 +1,57 @@
+package action
+
+import (
+	"errors"
+	"example.com/micro/fakeauth"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidateAccountSeed(t *testing.T) {
+	setupFakeAuth(t, fakeauth.Config{})
+	path := t.TempDir() + "/account.json"
+	seed := `[
+		{"username": "ok.stg", "password": "secret", "type": "EMPLOYEE", "domainType": "stg"},
+		{"username": "nowhere", "password": "secret", "type": "EMPLOYEE", "domainType": "prd"},
+		{"username": "nopassword.dev", "type": "EMPLOYE", "domainType": "dev"},
+		{"username": "typo.stg", "password": "secret", "type": "EMPLOYEE", "domaintype": "stg"},
+		{"username": "ok.stg", "password": "other", "type": "CUSTOMER", "domainType": "stg"},
+		{"username": "probe.stg", "password": "secret", "type": "EMPLOYEE", "domainType": "stg", "probes": [{"api": "GET::/me", "expect": 200}]},
+		{"username": 42, "password": "secret", "type": "EMPLOYEE", "domainType": "stg"},
+		null
+	]`
+	if err := os.WriteFile(path, []byte(seed), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	accounts, invalid, err := ValidateAccountSeed(path)
+	if err != nil {
+		t.Fatalf("ValidateAccountSeed: %v", err)
+	}
+	var got []string
+	for _, problem := range invalid {
+		got = append(got, problem.Error())
+	}
+	want := []string{
+		`accounts[1].domainType: unknown domainType "prd" (valid: dev, stg)`,
+		`accounts[2].password: is required`,
+		`accounts[2].type: unknown account type "EMPLOYE" (valid: EMPLOYEE, CUSTOMER, SELLER, SUPPLIER)`,
+		`accounts[3].domaintype: unknown field, did you mean "domainType"?`,
+		`accounts[4].username: "ok.stg" is already accounts[0] in stg`,
+		`accounts[5].probes[0].expect: unknown field`,
+		`accounts[6].username: number where string is expected`,
+		`accounts[7]: null account`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if len(accounts) != 4 {
+		t.Errorf("%d decoded account(s), want 4", len(accounts))
+	}
+
+	var seedErr *SeedError
+	if err := ImportAccountSeed(path); !errors.As(err, &seedErr) || len(seedErr.Invalid) != len(want) {
+		t.Fatalf("ImportAccountSeed = %v, want a SeedError listing every problem", err)
+	}
+}
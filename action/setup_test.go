@@ -0,0 +1,145 @@
+package action
+
+import (
+	"errors"
+	"example.com/micro/client/login"
+	"example.com/micro/config"
+	"example.com/micro/fakeauth"
+	"example.com/micro/model"
+	"example.com/micro/secret"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type staticSource []*model.Account
+
+func (s staticSource) ActiveAccounts(domainType string) ([]*model.Account, error) {
+	var accounts []*model.Account
+	for _, account := range s {
+		if account.Status == model.AccountStatus.Active && (domainType == "" || account.DomainType == domainType) {
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts, nil
+}
+
+// setupFakeAuth points stg and dev from environment.json at a fake auth
+// server through their base-URL overrides, and logs in accounts instead of
+// reading them from Mongo.
+func setupFakeAuth(t *testing.T, cfg fakeauth.Config, accounts ...*model.Account) *fakeauth.Server {
+	t.Helper()
+	t.Setenv("INSECURE_DEV", "true")
+	if err := secret.Init(); err != nil {
+		t.Fatalf("secret.Init: %v", err)
+	}
+
+	fake := fakeauth.New(cfg)
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	t.Setenv(config.BaseURLVariable("stg"), srv.URL)
+	t.Setenv(config.BaseURLVariable("dev"), srv.URL)
+
+	if err := config.LoadEnvironments("../environment.json"); err != nil {
+		t.Fatalf("LoadEnvironments: %v", err)
+	}
+	for _, env := range config.Environments {
+		env.RateLimit = -1
+	}
+	login.InitLoginClients()
+	login.Retry.BaseDelay = time.Millisecond
+	login.Retry.MaxDelay = 10 * time.Millisecond
+
+	SetAccountSource(staticSource(accounts))
+	t.Cleanup(func() { SetAccountSource(RegistrySource{}) })
+	SetLeaderLock(&memoryLock{})
+	t.Cleanup(func() { SetLeaderLock(MongoLeaderLock{}) })
+	return fake
+}
+
+// memoryLock is a run lock shared by the instances of a single process.
+type memoryLock struct {
+	mu    sync.Mutex
+	token int64
+	runID string
+}
+
+func (l *memoryLock) Acquire(runID string) (*LockGrant, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.runID != "" {
+		return nil, ErrRunInProgress
+	}
+	l.token++
+	l.runID = runID
+	return &LockGrant{Holder: "test", Token: l.token, RunID: runID}, nil
+}
+
+func (l *memoryLock) Takeover() (*LockGrant, error) {
+	return nil, nil
+}
+
+func (l *memoryLock) Renew(grant *LockGrant) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if grant.Token != l.token {
+		return ErrLockLost
+	}
+	return nil
+}
+
+func (l *memoryLock) Release(grant *LockGrant) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if grant.Token != l.token {
+		return ErrLockLost
+	}
+	l.runID = ""
+	return nil
+}
+
+// steal hands the lock to another instance, as a takeover would.
+func (l *memoryLock) steal() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.token++
+}
+
+func account(domainType, username, password string) *model.Account {
+	return &model.Account{
+		Username:   username,
+		Password:   password,
+		Type:       "EMPLOYEE",
+		DomainType: domainType,
+		Status:     model.AccountStatus.Active,
+	}
+}
+
+func withSeed(a *model.Account, seed string) *model.Account {
+	a.TOTPSeed = seed
+	return a
+}
+
+func leased(a *model.Account, until time.Time) *model.Account {
+	a.LeaseHolder = "qa@example.com"
+	a.LeaseExpiredTime = &until
+	return a
+}
+
+// waitIdle waits for the run in flight to finish: a run for an unknown
+// account is refused until then, and logs nobody in once it is accepted.
+func waitIdle(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err := RunAutoLogin(RunOption{Trigger: model.RunTrigger.API, Environment: "stg", Username: "nobody.stg"})
+		if !errors.Is(err, ErrRunInProgress) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("run still in flight after 5s")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
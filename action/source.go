@@ -0,0 +1,24 @@
+package action
+
+import "example.com/micro/model"
+
+// AccountSource loads the active accounts a login run covers, restricted to
+// one environment when domainType is set.
+type AccountSource interface {
+	ActiveAccounts(domainType string) ([]*model.Account, error)
+}
+
+// RegistrySource reads accounts from the Mongo account registry, which an
+// account file may define.
+type RegistrySource struct{}
+
+func (RegistrySource) ActiveAccounts(domainType string) ([]*model.Account, error) {
+	return getActiveAccounts(domainType)
+}
+
+var accountSource AccountSource = RegistrySource{}
+
+// SetAccountSource replaces where login runs read their accounts from.
+func SetAccountSource(source AccountSource) {
+	accountSource = source
+}
@@ -0,0 +1,144 @@
+package action
+
+import (
+	"example.com/micro/client/login"
+	"example.com/micro/config"
+	"example.com/micro/model"
+	"example.com/micro/model/core/obj"
+	"example.com/micro/secret"
+	"fmt"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/common"
+	"time"
+)
+
+// tokenMinValidity is how long a stored token must still be valid to be
+// handed out; anything closer to expiry is replaced by a new login.
+const tokenMinValidity = 5 * time.Minute
+
+// captureToken stores the session returned by a successful login, replacing
+// the previous one of the account.
+func captureToken(runID string, account *model.Account, result *login.Result) {
+	if result.Token == "" {
+		return
+	}
+	token, err := secret.Encrypt(result.Token)
+	if err != nil {
+		fmt.Printf("Cannot encrypt token of %s (%s): %v\n", account.Username, account.DomainType, err)
+		return
+	}
+
+	now := time.Now()
+	expiredTime := result.TokenExpiry
+	if expiredTime == nil {
+		expiredTime = obj.WithTime(now.Add(config.TokenTTL))
+	}
+	// Refresh TokenRefreshMargin ahead of expiry, or at half of the
+	// lifetime for sessions shorter than twice the margin.
+	refreshTime := expiredTime.Add(-config.TokenRefreshMargin)
+	if halfLife := now.Add(expiredTime.Sub(now) / 2); refreshTime.Before(halfLife) {
+		refreshTime = halfLife
+	}
+	model.DBSessionToken.Upsert(model.SessionToken{
+		Username:   account.Username,
+		DomainType: account.DomainType,
+	}, model.SessionToken{
+		Username:     account.Username,
+		DomainType:   account.DomainType,
+		Token:        token,
+		ExpiredTime:  expiredTime,
+		RefreshTime:  &refreshTime,
+		CapturedTime: &now,
+		RunID:        runID,
+	})
+}
+
+// GetSessionToken returns a valid session of the account, logging it in on
+// the spot when the stored one is missing or about to expire. A leased
+// account is refused: its session belongs to the lease holder.
+func GetSessionToken(domainType, username string) *common.APIResponse {
+	token, failure := sessionToken(domainType, username)
+	if failure != nil {
+		return failure
+	}
+	return revealToken(token)
+}
+
+// sessionToken returns the stored session of the account, still sealed,
+// after renewing it when needed. The response explains why there is none.
+func sessionToken(domainType, username string) (*model.SessionToken, *common.APIResponse) {
+	existed := model.DBAccount.QueryOne(model.Account{Username: username, DomainType: domainType})
+	if existed.Status != common.APIStatus.Ok {
+		return nil, accountNotFound()
+	}
+	account := existed.Data.([]*model.Account)[0]
+	if account.Leased(time.Now()) {
+		return nil, &common.APIResponse{
+			Status:    common.APIStatus.Existed,
+			Message:   "Account " + username + " is leased until " + account.LeaseExpiredTime.Format(time.RFC3339),
+			ErrorCode: "ACCOUNT_LEASED",
+		}
+	}
+
+	query := model.SessionToken{Username: username, DomainType: domainType}
+	if resp := model.DBSessionToken.QueryOne(query); resp.Status == common.APIStatus.Ok {
+		token := resp.Data.([]*model.SessionToken)[0]
+		if token.ExpiredTime != nil && token.ExpiredTime.After(time.Now().Add(tokenMinValidity)) {
+			return token, nil
+		}
+	}
+
+	if account.Status != model.AccountStatus.Active {
+		return nil, &common.APIResponse{
+			Status:    common.APIStatus.Invalid,
+			Message:   "Account " + username + " is " + account.Status,
+			ErrorCode: "ACCOUNT_NOT_ACTIVE",
+		}
+	}
+
+	return loginForToken(account)
+}
+
+// loginForToken logs the account in now, recording the attempt as a run
+// would, and returns the session it captured.
+func loginForToken(account *model.Account) (*model.SessionToken, *common.APIResponse) {
+	attempt := loginAccount("", account)
+	recordAttempt(account, attempt)
+	if attempt.Status == model.AttemptStatus.Failed || attempt.Status == model.AttemptStatus.ConfigError {
+		return nil, &common.APIResponse{
+			Status:    common.APIStatus.Error,
+			Message:   "Login failed: " + attempt.Message,
+			ErrorCode: "LOGIN_FAILED",
+		}
+	}
+
+	resp := model.DBSessionToken.QueryOne(model.SessionToken{Username: account.Username, DomainType: account.DomainType})
+	if resp.Status != common.APIStatus.Ok {
+		return nil, tokenNotFound(account)
+	}
+	return resp.Data.([]*model.SessionToken)[0], nil
+}
+
+func tokenNotFound(account *model.Account) *common.APIResponse {
+	return &common.APIResponse{
+		Status:    common.APIStatus.NotFound,
+		Message:   "Login response of " + account.Username + " carries no session token",
+		ErrorCode: "TOKEN_NOT_FOUND",
+	}
+}
+
+func revealToken(token *model.SessionToken) *common.APIResponse {
+	plaintext, err := secret.Decrypt(token.Token)
+	if err != nil {
+		return &common.APIResponse{
+			Status:    common.APIStatus.Error,
+			Message:   "Cannot decrypt token: " + err.Error(),
+			ErrorCode: "DECRYPTION_FAILED",
+		}
+	}
+	token.Token = plaintext
+	return &common.APIResponse{
+		Status:  common.APIStatus.Ok,
+		Message: "Session token of " + token.Username,
+		Data:    []*model.SessionToken{token},
+	}
+}
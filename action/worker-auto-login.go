@@ -1,90 +1,476 @@
 package action
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"example.com/micro/client"
 	"example.com/micro/client/login"
+	"example.com/micro/config"
 	"example.com/micro/model"
+	"example.com/micro/model/core/obj"
+	"example.com/micro/notify"
+	"example.com/micro/secret"
+	"example.com/micro/totp"
 	"fmt"
 	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/common"
-	"log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+var ErrRunInProgress = errors.New("a login run is already in progress or the service is stopping")
+
+// runLock is held for the whole duration of a login run. Shutdown takes it
+// for good so that no new run starts once the service is stopping.
+var runLock sync.Mutex
+
+// RunOption describes what a login run covers and who asked for it.
+type RunOption struct {
+	Trigger     string
+	Environment string // empty means every environment
+	Username    string // one account of Environment, empty means all
+	Type        string // account type, empty means all
+}
+
+// AutoLoginTask logs in every active account. A call made while another run
+// is in flight, or after Shutdown, is skipped.
 func AutoLoginTask() {
+	if _, err := RunAutoLogin(RunOption{Trigger: model.RunTrigger.Worker}); err != nil {
+		fmt.Println("Worker skipped: ", err)
+	}
+}
+
+// RunAutoLogin executes one login run and records it with its attempts.
+func RunAutoLogin(opt RunOption) (*model.LoginRun, error) {
+	if !runLock.TryLock() {
+		return nil, ErrRunInProgress
+	}
+	defer runLock.Unlock()
+
+	run, grant, err := startRun(opt)
+	if err != nil {
+		return nil, err
+	}
+	executeRun(run, opt, grant, nil)
+	return run, nil
+}
+
+// StartAutoLogin starts a login run in the background and returns it as
+// recorded at its start; GetRun follows its progress.
+func StartAutoLogin(opt RunOption) (*model.LoginRun, error) {
+	if !runLock.TryLock() {
+		return nil, ErrRunInProgress
+	}
+
+	run, grant, err := startRun(opt)
+	if err != nil {
+		runLock.Unlock()
+		return nil, err
+	}
+	started := *run
+	go func() {
+		defer runLock.Unlock()
+		executeRun(run, opt, grant, nil)
+	}()
+	return &started, nil
+}
+
+// executeRun logs in the accounts of the run, except the ones done already
+// when resuming it, while renewing the run lock. A run whose lock is taken
+// over stops dispatching accounts and leaves its record to the new holder.
+func executeRun(run *model.LoginRun, opt RunOption, grant *LockGrant, done []*model.LoginAttempt) {
+	hb := keepLock(grant)
+	defer hb.release()
+
 	fmt.Println("Worker running!")
-	content, err := os.ReadFile("./account.json")
+	payload, err := accountSource.ActiveAccounts(opt.Environment)
 	if err != nil {
-		log.Fatal("Error when opening file: ", err)
+		fmt.Println("Error when loading accounts: ", err)
+		finishRun(run, done, "Error when loading accounts: "+err.Error())
+		return
 	}
+	if opt.Username != "" {
+		payload = selectAccount(payload, opt.Username)
+		if len(payload) == 0 {
+			finishRun(run, done, "No active account "+opt.Username+" in "+opt.Environment)
+			return
+		}
+	}
+	if opt.Type != "" {
+		payload = selectType(payload, opt.Type)
+	}
+	payload = skipAttempted(payload, done)
+	payload, leased := skipLeased(payload, time.Now())
+	payload, skipped := selectBySession(opt.Trigger, payload, time.Now())
+	run.Skipped = obj.WithInt(skipped)
+
+	attempts, changes, lateLeased := loginAccounts(run.RunID, payload, config.LoginConcurrency, hb.Lost)
+	run.Leased = obj.WithInt(leased + lateLeased)
+	if hb.Lost() {
+		fmt.Printf("Run %s stopped after %d account(s), its new holder resumes it\n", run.RunID, len(attempts))
+		return
+	}
+	if opt.Trigger == model.RunTrigger.Refresh {
+		postponeFailedRefreshes(attempts)
+	}
+	attempts = append(done, attempts...)
+	finishRun(run, attempts, "")
+	printReport(run, attempts)
+	notifyRun(run, changes)
+}
 
-	// Now let's unmarshall the data into `payload`
-	var payload []model.Account
-	err = json.Unmarshal(content, &payload)
+func selectAccount(accounts []*model.Account, username string) []*model.Account {
+	for _, account := range accounts {
+		if account.Username == username {
+			return []*model.Account{account}
+		}
+	}
+	return nil
+}
+
+func selectType(accounts []*model.Account, accountType string) []*model.Account {
+	selected := make([]*model.Account, 0, len(accounts))
+	for _, account := range accounts {
+		if account.Type == accountType {
+			selected = append(selected, account)
+		}
+	}
+	return selected
+}
+
+// startRun takes the run lock for a new run and records the run.
+func startRun(opt RunOption) (*model.LoginRun, *LockGrant, error) {
+	runID := primitive.NewObjectID().Hex()
+	grant, err := leaderLock.Acquire(runID)
 	if err != nil {
-		log.Fatal("Error during Unmarshal(): ", err)
-	}
-	countFail := 0
-	countSuccess := 0
-	var listStgFail []string
-	var listDevFail []string
-	var listNotHaveDomain []string
-	for _, v := range payload {
-		if v.DomainType == model.STG {
-			result := login.FuncLoginStg(client.APIOption{
-				Body: payloadToBody(v),
-			})
-			if result.Status == common.APIStatus.Ok {
-				countSuccess++
-			} else {
-				countFail++
-				listStgFail = append(listStgFail, v.Username)
-			}
-		} else if v.DomainType == model.DEV {
-			result := login.FuncLoginDev(client.APIOption{
-				Body: payloadToBody(v),
-			})
-			if result.Status == common.APIStatus.Ok {
-				countSuccess++
-			} else {
-				countFail++
-				listDevFail = append(listDevFail, v.Username)
+		return nil, nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	run := &model.LoginRun{
+		RunID:       runID,
+		Trigger:     opt.Trigger,
+		Environment: opt.Environment,
+		Username:    opt.Username,
+		AccountType: opt.Type,
+		Hostname:    hostname,
+		Status:      model.RunStatus.Running,
+		StartTime:   obj.WithTime(time.Now()),
+		LockToken:   grant.Token,
+	}
+	model.DBLoginRun.Create(run)
+	return run, grant, nil
+}
+
+// finishRun records the outcome of the run, unless its executor lost the
+// run lock in the meantime: the update is fenced by the lock token.
+func finishRun(run *model.LoginRun, attempts []*model.LoginAttempt, message string) {
+	success, fail, verifyFail, configError, paused := 0, 0, 0, 0, 0
+	for _, attempt := range attempts {
+		if attempt.Paused {
+			paused++
+		}
+		switch attempt.Status {
+		case model.AttemptStatus.Success:
+			success++
+		case model.AttemptStatus.Failed:
+			fail++
+		case model.AttemptStatus.VerifyFailed:
+			verifyFail++
+		default:
+			configError++
+		}
+	}
+
+	run.EndTime = obj.WithTime(time.Now())
+	run.Message = message
+	run.Total = obj.WithInt(len(attempts))
+	run.Success = obj.WithInt(success)
+	run.Fail = obj.WithInt(fail)
+	run.VerifyFail = obj.WithInt(verifyFail)
+	run.ConfigError = obj.WithInt(configError)
+	run.Paused = obj.WithInt(paused)
+	switch {
+	case message != "" || (len(attempts) > 0 && success == 0):
+		run.Status = model.RunStatus.Failed
+	case fail > 0 || verifyFail > 0 || configError > 0:
+		run.Status = model.RunStatus.Partial
+	default:
+		run.Status = model.RunStatus.Success
+	}
+
+	model.DBLoginRun.UpdateOne(bson.M{"run_id": run.RunID, "lock_token": run.LockToken}, model.LoginRun{
+		Status:      run.Status,
+		Message:     run.Message,
+		EndTime:     run.EndTime,
+		Total:       run.Total,
+		Success:     run.Success,
+		Fail:        run.Fail,
+		VerifyFail:  run.VerifyFail,
+		ConfigError: run.ConfigError,
+		Skipped:     run.Skipped,
+		Paused:      run.Paused,
+		Leased:      run.Leased,
+	})
+}
+
+// loginAccounts logs accounts in with a pool of workers, until stopped says
+// so. Attempts and changes keep the order of accounts, whatever order the
+// logins complete in, so the report is the same from one run to the next.
+// An account leased since the run started is left out, and counted in the
+// third result.
+func loginAccounts(runID string, accounts []*model.Account, workers int, stopped func() bool) ([]*model.LoginAttempt, []*notify.Change, int) {
+	if workers < 1 {
+		workers = 1
+	}
+	attempts := make([]*model.LoginAttempt, len(accounts))
+	changes := make([]*notify.Change, len(accounts))
+	var leased atomic.Int32
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if stopped() {
+					continue
+				}
+				if leasedNow(accounts[i], time.Now()) {
+					leased.Add(1)
+					continue
+				}
+				attempt := loginAccount(runID, accounts[i])
+				changes[i] = recordAttempt(accounts[i], attempt)
+				attempts[i] = attempt
 			}
+		}()
+	}
+	for i := range accounts {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	dispatched := attempts[:0]
+	for _, attempt := range attempts {
+		if attempt != nil {
+			dispatched = append(dispatched, attempt)
+		}
+	}
+	var changed []*notify.Change
+	for _, change := range changes {
+		if change != nil {
+			changed = append(changed, change)
+		}
+	}
+	return dispatched, changed, int(leased.Load())
+}
+
+// recordAttempt counts the attempt against the account, pausing it when its
+// failures reach the threshold, and keeps the attempt in the history. Every
+// login goes through it, in a run or not, so that a wrong password pauses the
+// account before the auth service locks it out.
+func recordAttempt(account *model.Account, attempt *model.LoginAttempt) *notify.Change {
+	change := trackState(account, attempt)
+	model.DBLoginAttempt.Create(attempt)
+	observeAttempt(attempt)
+	return change
+}
+
+// TryLogin logs one account in, whatever its status, as a run would. The
+// attempt is recorded outside of any run and counts against the account.
+func TryLogin(account *model.Account) *model.LoginAttempt {
+	attempt := loginAccount("", account)
+	recordAttempt(account, attempt)
+	return attempt
+}
+
+// loginAccount logs one account in, keeps its session and describes the
+// outcome as an attempt.
+func loginAccount(runID string, account *model.Account) *model.LoginAttempt {
+	attempt, result := signIn(runID, account)
+	if attempt.Status != model.AttemptStatus.Success {
+		return attempt
+	}
+	captureToken(runID, account, result)
+	verifySession(account, result.Token, attempt)
+	return attempt
+}
+
+// signIn sends the login of one account, completing its TOTP challenge. The
+// result is only set when the attempt is a success.
+func signIn(runID string, account *model.Account) (*model.LoginAttempt, *login.Result) {
+	attempt := &model.LoginAttempt{
+		RunID:      runID,
+		Username:   account.Username,
+		Type:       account.Type,
+		DomainType: account.DomainType,
+		StartTime:  obj.WithTime(time.Now()),
+	}
+
+	if !login.IsSupported(account.DomainType) {
+		attempt.Status = model.AttemptStatus.ConfigError
+		attempt.ErrorCode = "UNKNOWN_DOMAIN_TYPE"
+		attempt.Message = fmt.Sprintf("unknown domainType %q", account.DomainType)
+		return attempt, nil
+	}
+
+	body, err := payloadToBody(account)
+	if err != nil {
+		attempt.Status = model.AttemptStatus.ConfigError
+		attempt.ErrorCode = "INVALID_CREDENTIAL"
+		attempt.Message = err.Error()
+		return attempt, nil
+	}
+
+	result := login.FuncLogin(account.DomainType, client.APIOption{
+		Body: body,
+	})
+	if result.Status == common.APIStatus.Ok && result.OTPChallenge != "" {
+		if account.TOTPSeed == "" {
+			attempt.Status = model.AttemptStatus.ConfigError
+			attempt.ErrorCode = "OTP_SEED_MISSING"
+			attempt.Message = "login asks for a TOTP code but the account has no seed"
+			return attempt, nil
+		}
+		seed, err := secret.Decrypt(account.TOTPSeed)
+		if err == nil {
+			_, err = totp.DecodeSeed(seed)
+		}
+		if err != nil {
+			attempt.Status = model.AttemptStatus.ConfigError
+			attempt.ErrorCode = "INVALID_OTP_SEED"
+			attempt.Message = err.Error()
+			return attempt, nil
+		}
+		result = login.CompleteOTP(account.DomainType, result, seed)
+	}
+	attempt.HTTPCode = result.HTTPCode
+	attempt.ErrorCode = result.ErrorCode
+	attempt.Message = result.Message
+	attempt.Class = result.Class
+	attempt.Retries = result.Retries
+	attempt.LatencyMs = result.Latency.Milliseconds()
+	if result.Status != common.APIStatus.Ok {
+		attempt.Status = model.AttemptStatus.Failed
+		return attempt, nil
+	}
+
+	attempt.Status = model.AttemptStatus.Success
+	return attempt, result
+}
+
+// verifySession runs the account's probes, stopping at the first failure,
+// which turns the attempt into a verification failure.
+func verifySession(account *model.Account, token string, attempt *model.LoginAttempt) {
+	for i, probe := range account.Probes {
+		name := probe.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d %s", i, probe.API)
+		}
+
+		var err error
+		if token == "" {
+			err = errors.New("login response carries no session token")
 		} else {
-			listNotHaveDomain = append(listNotHaveDomain, v.Username)
+			err = login.Verify(account.DomainType, token, probe)
+		}
+		if err != nil {
+			attempt.Status = model.AttemptStatus.VerifyFailed
+			attempt.ErrorCode = "VERIFY_FAILED"
+			attempt.FailedProbe = name
+			attempt.Message = fmt.Sprintf("probe %s: %v", name, err)
+			return
+		}
+	}
+}
+
+func printReport(run *model.LoginRun, attempts []*model.LoginAttempt) {
+	fmt.Printf("Worker run %s done with %d account!\n%d Successfully\n%d Fail\n%d Verification fail\n%d Configuration error", run.RunID, *run.Total, *run.Success, *run.Fail, *run.VerifyFail, *run.ConfigError)
+	if run.Skipped != nil && *run.Skipped > 0 {
+		fmt.Printf("\n%d Skipped with a live session", *run.Skipped)
+	}
+	if run.Leased != nil && *run.Leased > 0 {
+		fmt.Printf("\n%d Skipped while leased", *run.Leased)
+	}
+
+	if *run.Fail > 0 {
+		fmt.Printf("\nList username fail:")
+		for _, domainType := range config.EnvironmentNames() {
+			for _, v := range attempts {
+				if v.Status == model.AttemptStatus.Failed && v.DomainType == domainType {
+					fmt.Printf("\n- %s__%s (%s, %d %s)", domainType, v.Username, v.Class, v.HTTPCode, v.ErrorCode)
+				}
+			}
 		}
 	}
-	fmt.Printf("Worker run task done with %d account!\n%d Successfully\n%d Fail\n%d Missing domainType", len(payload), countSuccess, countFail, len(listNotHaveDomain))
 
-	if countFail > 0 {
-		if len(listStgFail) > 0 {
-			fmt.Printf("\nList username fail:")
-			for _, v := range listStgFail {
-				fmt.Printf("\n- %s__%s", "stg", v)
+	if *run.VerifyFail > 0 {
+		fmt.Printf("\nList verification fail:")
+		for _, v := range attempts {
+			if v.Status == model.AttemptStatus.VerifyFailed {
+				fmt.Printf("\n- %s__%s (%s)", v.DomainType, v.Username, v.Message)
 			}
 		}
+	}
 
-		if len(listDevFail) > 0 {
-			for _, v := range listDevFail {
-				fmt.Printf("\n- %s__%s", "dev", v)
+	if *run.Paused > 0 {
+		fmt.Printf("\nList paused after consecutive failures:")
+		for _, v := range attempts {
+			if v.Paused {
+				fmt.Printf("\n- %s__%s", v.DomainType, v.Username)
 			}
 		}
 	}
 
-	if len(listNotHaveDomain) > 0 {
-		fmt.Printf("\nList missing domainType:")
+	if *run.ConfigError > 0 {
+		fmt.Printf("\nList configuration error:")
 
-		for _, v := range listNotHaveDomain {
-			fmt.Printf("\n- %s", v)
+		for _, v := range attempts {
+			if v.Status == model.AttemptStatus.ConfigError {
+				fmt.Printf("\n- %s (%s: %s)", v.Username, v.DomainType, v.Message)
+			}
 		}
 	}
 	fmt.Println("\nWorker end!")
 }
 
-func payloadToBody(account model.Account) map[string]interface{} {
+// Shutdown blocks new runs and waits for the in-flight one to finish, until
+// ctx is done.
+func Shutdown(ctx context.Context) error {
+	return acquireRunLock(ctx)
+}
+
+func acquireRunLock(ctx context.Context) error {
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		if runLock.TryLock() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C:
+		}
+	}
+}
+
+// payloadToBody decrypts the account password only for the login body.
+func payloadToBody(account *model.Account) (map[string]interface{}, error) {
+	password, err := secret.Decrypt(account.Password)
+	if err != nil {
+		return nil, err
+	}
+
 	return map[string]interface{}{
 		"username": account.Username,
-		"password": account.Password,
+		"password": password,
 		"type":     account.Type,
-	}
+	}, nil
 }
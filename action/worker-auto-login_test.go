@@ -0,0 +1,120 @@
+package action
+
+import (
+	"example.com/micro/config"
+	"example.com/micro/fakeauth"
+	"example.com/micro/model"
+	"testing"
+)
+
+func TestAutoLoginFiltersEnvironment(t *testing.T) {
+	fake := setupFakeAuth(t, fakeauth.Config{
+		Users: []*fakeauth.User{
+			{Username: "ok.stg", Password: "secret"},
+			{Username: "ok.dev", Password: "secret"},
+		},
+	},
+		account("stg", "ok.stg", "secret"),
+		account("dev", "ok.dev", "secret"),
+	)
+
+	run, err := RunAutoLogin(RunOption{Trigger: model.RunTrigger.API, Environment: "dev"})
+	if err != nil {
+		t.Fatalf("RunAutoLogin: %v", err)
+	}
+	if run.Status != model.RunStatus.Success || *run.Total != 1 {
+		t.Fatalf("run %s with %d account(s), want SUCCESS with 1", run.Status, *run.Total)
+	}
+	if fake.Calls("ok.stg") != 0 || fake.Calls("ok.dev") != 1 {
+		t.Fatalf("calls stg=%d dev=%d, want only dev", fake.Calls("ok.stg"), fake.Calls("ok.dev"))
+	}
+}
+
+func TestAutoLoginTaskTripsFakeLockout(t *testing.T) {
+	fake := setupFakeAuth(t, fakeauth.Config{
+		LockoutThreshold: 2,
+		Users: []*fakeauth.User{
+			{Username: "changed.stg", Password: "new-password"},
+		},
+	},
+		account("stg", "changed.stg", "old-password"),
+	)
+
+	for i := 0; i < 3; i++ {
+		AutoLoginTask()
+	}
+	if !fake.Locked("changed.stg") {
+		t.Fatal("account not locked after repeated wrong passwords")
+	}
+	if got := fake.Calls("changed.stg"); got != 3 {
+		t.Fatalf("account called %d times, want one call per run", got)
+	}
+}
+
+func TestAutoLoginCompletesTOTP(t *testing.T) {
+	const seed = "JBSWY3DPEHPK3PXP"
+	fake := setupFakeAuth(t, fakeauth.Config{
+		Users: []*fakeauth.User{
+			{Username: "otp.stg", Password: "secret", TOTPSeed: seed},
+			{Username: "wrongseed.stg", Password: "secret", TOTPSeed: seed},
+			{Username: "noseed.stg", Password: "secret", TOTPSeed: seed},
+		},
+	},
+		withSeed(account("stg", "otp.stg", "secret"), seed),
+		withSeed(account("stg", "wrongseed.stg", "secret"), "KRSXG5CTMVRXEZLU"),
+		account("stg", "noseed.stg", "secret"),
+	)
+
+	run, err := RunAutoLogin(RunOption{Trigger: model.RunTrigger.API})
+	if err != nil {
+		t.Fatalf("RunAutoLogin: %v", err)
+	}
+	if *run.Success != 1 || *run.Fail != 1 || *run.ConfigError != 1 {
+		t.Fatalf("success %d, fail %d, config error %d; want 1/1/1", *run.Success, *run.Fail, *run.ConfigError)
+	}
+	if got := fake.Calls("otp.stg"); got != 2 {
+		t.Errorf("otp account called %d times, want login and OTP step", got)
+	}
+	if got := fake.Calls("wrongseed.stg"); got != 2 {
+		t.Errorf("wrong seed called %d times, want 2 (a rejected code is never retried)", got)
+	}
+}
+
+func TestAutoLoginFiltersType(t *testing.T) {
+	supplier := account("stg", "supplier.stg", "secret")
+	supplier.Type = "SUPPLIER"
+	fake := setupFakeAuth(t, fakeauth.Config{
+		Users: []*fakeauth.User{
+			{Username: "employee.stg", Password: "secret"},
+			{Username: "supplier.stg", Password: "secret"},
+		},
+	}, account("stg", "employee.stg", "secret"), supplier)
+
+	run, err := RunAutoLogin(RunOption{Trigger: model.RunTrigger.CLI, Environment: "stg", Type: "EMPLOYEE"})
+	if err != nil {
+		t.Fatalf("RunAutoLogin: %v", err)
+	}
+	if run.Status != model.RunStatus.Success || *run.Total != 1 || run.AccountType != "EMPLOYEE" {
+		t.Fatalf("run %s over %d %q account(s), want SUCCESS over 1 EMPLOYEE", run.Status, *run.Total, run.AccountType)
+	}
+	if fake.Calls("employee.stg") != 1 || fake.Calls("supplier.stg") != 0 {
+		t.Fatalf("calls employee=%d supplier=%d, want only the employee", fake.Calls("employee.stg"), fake.Calls("supplier.stg"))
+	}
+}
+
+func TestTryLoginCountsFailures(t *testing.T) {
+	setupFakeAuth(t, fakeauth.Config{
+		Users: []*fakeauth.User{{Username: "wrong.stg", Password: "changed"}},
+	})
+	threshold := config.PauseThreshold
+	config.PauseThreshold = 2
+	t.Cleanup(func() { config.PauseThreshold = threshold })
+
+	wrong := account("stg", "wrong.stg", "secret")
+	for i := 0; i < 2; i++ {
+		TryLogin(wrong)
+	}
+	if wrong.Status != model.AccountStatus.Paused || *wrong.ConsecutiveFailures != 2 {
+		t.Fatalf("account %s after %d failure(s), want PAUSED after 2", wrong.Status, *wrong.ConsecutiveFailures)
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"example.com/micro/action"
+	"example.com/micro/model"
+	"example.com/micro/model/core/obj"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk"
+)
+
+// AccountCreate POST /accounts
+func AccountCreate(req sdk.APIRequest, resp sdk.APIResponder) error {
+	var input model.Account
+	if err := req.GetContent(&input); err != nil {
+		return resp.Respond(obj.WithInvalidInput(err))
+	}
+	return resp.Respond(action.CreateAccount(&input))
+}
+
+// AccountList GET /accounts
+func AccountList(req sdk.APIRequest, resp sdk.APIResponder) error {
+	filter := &model.Account{
+		Username:   req.GetParam("username"),
+		Type:       req.GetParam("type"),
+		DomainType: req.GetParam("domainType"),
+		Status:     req.GetParam("status"),
+	}
+	offset := sdk.ParseInt64(req.GetParam("offset"), 0)
+	limit := sdk.ParseInt64(req.GetParam("limit"), 100)
+	return resp.Respond(action.GetAccountList(filter, offset, limit))
+}
+
+// AccountUpdate PUT /accounts/:id
+func AccountUpdate(req sdk.APIRequest, resp sdk.APIResponder) error {
+	var input model.Account
+	if err := req.GetContent(&input); err != nil {
+		return resp.Respond(obj.WithInvalidInput(err))
+	}
+	return resp.Respond(action.UpdateAccount(req.GetVar("id"), &input))
+}
+
+// AccountDisable PUT /accounts/:id/disable
+func AccountDisable(req sdk.APIRequest, resp sdk.APIResponder) error {
+	return resp.Respond(action.DisableAccount(req.GetVar("id")))
+}
+
+// AccountEnable PUT /accounts/:id/enable
+func AccountEnable(req sdk.APIRequest, resp sdk.APIResponder) error {
+	return resp.Respond(action.EnableAccount(req.GetVar("id")))
+}
+
+// AccountDelete DELETE /accounts/:id
+func AccountDelete(req sdk.APIRequest, resp sdk.APIResponder) error {
+	return resp.Respond(action.DeleteAccount(req.GetVar("id")))
+}
+
+// AccountReloadList GET /account-reloads
+func AccountReloadList(req sdk.APIRequest, resp sdk.APIResponder) error {
+	offset := sdk.ParseInt64(req.GetParam("offset"), 0)
+	limit := sdk.ParseInt64(req.GetParam("limit"), 20)
+	return resp.Respond(action.GetAccountReloadList(offset, limit))
+}
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"example.com/micro/auth"
+	"example.com/micro/config"
+	"example.com/micro/metrics"
+	"example.com/micro/secret"
+	"github.com/labstack/echo"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/common"
+	"strings"
+)
+
+const (
+	callerAttribute = "caller"
+	metricsPath     = "/metrics"
+)
+
+// route is one admin API endpoint with the least role allowed to call it.
+type route struct {
+	method  *common.MethodValue
+	path    string
+	role    auth.Role
+	handler sdk.Handler
+}
+
+// Caller is who sent the request, set on it by Authorize. APIKey tells a
+// caller identified by its X-API-Key header.
+type Caller struct {
+	Name   string
+	Role   auth.Role
+	APIKey bool
+}
+
+var (
+	verifier *auth.Verifier
+	policy   *auth.Policy
+	// anonymous lets callers without credentials in as admin, for local
+	// development without an access file.
+	anonymous bool
+
+	requiredRoles = make(map[string]auth.Role)
+)
+
+// InitAccess builds the verifier and the role policy from config.Access.
+// Without an access file the API is open to anyone, which only INSECURE_DEV
+// allows.
+func InitAccess() error {
+	access := config.Access
+	if access == nil {
+		if !secret.AllowPlaintext() {
+			return errors.New("no access file: the admin API would be open to anyone")
+		}
+		verifier, policy, anonymous = nil, nil, true
+		return nil
+	}
+
+	p, err := auth.NewPolicy(access.Bindings)
+	if err != nil {
+		return err
+	}
+	jwks := access.JWKS
+	if jwks == "" {
+		jwks = auth.GoogleJWKS
+	}
+	issuers := access.Issuers
+	if len(issuers) == 0 {
+		issuers = auth.GoogleIssuers
+	}
+	verifier = &auth.Verifier{Audience: access.Audience, Issuers: issuers, Keys: auth.NewJWKS(jwks)}
+	policy, anonymous = p, false
+	return nil
+}
+
+// routes is the admin API. Reading needs viewer; triggering runs, handing
+// out tokens and leases, and enabling or disabling accounts needs operator;
+// editing accounts and schedules needs admin.
+var routes = []route{
+	{common.APIMethod.POST, "/accounts", auth.Admin, AccountCreate},
+	{common.APIMethod.GET, "/accounts", auth.Viewer, AccountList},
+	{common.APIMethod.PUT, "/accounts/:id", auth.Admin, AccountUpdate},
+	{common.APIMethod.PUT, "/accounts/:id/disable", auth.Operator, AccountDisable},
+	{common.APIMethod.PUT, "/accounts/:id/enable", auth.Operator, AccountEnable},
+	{common.APIMethod.DELETE, "/accounts/:id", auth.Admin, AccountDelete},
+	{common.APIMethod.GET, "/account-reloads", auth.Viewer, AccountReloadList},
+	{common.APIMethod.POST, "/runs", auth.Operator, RunCreate},
+	{common.APIMethod.GET, "/runs", auth.Viewer, RunList},
+	{common.APIMethod.GET, "/runs/:runId", auth.Viewer, RunGet},
+	{common.APIMethod.GET, "/attempts", auth.Viewer, AttemptList},
+	{common.APIMethod.GET, "/schedules", auth.Viewer, ScheduleList},
+	{common.APIMethod.PUT, "/schedules/:topic", auth.Admin, ScheduleUpdate},
+	{common.APIMethod.PUT, "/schedules/:topic/pause", auth.Admin, SchedulePause},
+	{common.APIMethod.PUT, "/schedules/:topic/resume", auth.Admin, ScheduleResume},
+	{common.APIMethod.GET, "/tokens/:env/:username", auth.Operator, TokenGet},
+	{common.APIMethod.POST, "/leases", auth.Operator, LeaseCreate},
+	{common.APIMethod.GET, "/leases", auth.Viewer, LeaseList},
+	{common.APIMethod.PUT, "/leases/:leaseId/renew", auth.Operator, LeaseRenew},
+	{common.APIMethod.DELETE, "/leases/:leaseId", auth.Operator, LeaseRelease},
+	{common.APIMethod.GET, "/journey-runs", auth.Viewer, JourneyRunList},
+	{common.APIMethod.POST, "/journeys/:name/run", auth.Operator, JourneyRun},
+}
+
+// apiKeyRoutes are the only routes API keys may call, whatever their role:
+// test tooling reads tokens and does nothing else.
+var apiKeyRoutes = map[string]bool{
+	common.APIMethod.GET.Value + " /tokens/:env/:username": true,
+}
+
+// SetRoutes registers the admin API on server behind Authorize, and the
+// Prometheus metrics, open to scrapers without credentials.
+func SetRoutes(server sdk.APIServer) error {
+	for _, r := range routes {
+		requiredRoles[r.method.Value+" "+r.path] = r.role
+		if err := server.SetHandler(r.method, r.path, r.handler); err != nil {
+			return err
+		}
+	}
+	// The SDK responder only writes JSON envelopes, so the text format is
+	// served by Echo itself.
+	if s, ok := server.(*sdk.HTTPAPIServer); ok {
+		requiredRoles[common.APIMethod.GET.Value+" "+metricsPath] = auth.None
+		s.Echo.GET(metricsPath, echo.WrapHandler(metrics.Default.Handler()))
+	}
+	return server.PreRequest(Authorize)
+}
+
+// Authorize is the request pre-handler: it identifies the caller and refuses
+// the request when its role is below the one of the route. Routes registered
+// without SetRoutes require admin; routes requiring auth.None are public.
+func Authorize(req sdk.APIRequest, resp sdk.APIResponder) error {
+	key := req.GetMethod().Value + " " + req.GetPath()
+	required, ok := requiredRoles[key]
+	if !ok {
+		required = auth.Admin
+	}
+	if required == auth.None {
+		return nil
+	}
+
+	caller, failure := authenticate(req)
+	switch {
+	case failure != nil:
+	case caller.APIKey && !apiKeyRoutes[key]:
+		failure = &common.APIResponse{
+			Status:    common.APIStatus.Forbidden,
+			Message:   "API keys may only get tokens",
+			ErrorCode: "FORBIDDEN",
+		}
+	case caller.Role < required:
+		failure = &common.APIResponse{
+			Status:    common.APIStatus.Forbidden,
+			Message:   "Role " + required.String() + " is required, " + caller.Name + " is " + caller.Role.String(),
+			ErrorCode: "FORBIDDEN",
+		}
+	}
+	if failure != nil {
+		_ = resp.Respond(failure)
+		return errors.New(failure.Message)
+	}
+	req.SetAttribute(callerAttribute, caller)
+	return nil
+}
+
+// authenticate identifies the caller from its bearer ID token or its
+// X-API-Key header; API keys act as operator on apiKeyRoutes only, for test
+// tooling.
+func authenticate(req sdk.APIRequest) (*Caller, *common.APIResponse) {
+	if anonymous {
+		return &Caller{Name: "anonymous", Role: auth.Admin}, nil
+	}
+
+	if header := req.GetHeader("Authorization"); header != "" {
+		raw := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+		if raw == header {
+			return nil, unauthorized("Authorization must be a bearer ID token", "INVALID_TOKEN")
+		}
+		id, err := verifier.Verify(raw)
+		if err != nil {
+			return nil, unauthorized(err.Error(), "INVALID_TOKEN")
+		}
+		name := id.Email
+		if name == "" {
+			name = "subject " + id.Subject
+		}
+		return &Caller{Name: name, Role: policy.RoleOf(id)}, nil
+	}
+
+	if key := req.GetHeader("X-API-Key"); key != "" {
+		if !validAPIKey(key) {
+			return nil, unauthorized("Invalid X-API-Key header", "INVALID_API_KEY")
+		}
+		return &Caller{Name: "API key", Role: auth.Operator, APIKey: true}, nil
+	}
+	return nil, unauthorized("A bearer ID token or an X-API-Key header is required", "AUTHENTICATION_REQUIRED")
+}
+
+func unauthorized(message, code string) *common.APIResponse {
+	return &common.APIResponse{
+		Status:    common.APIStatus.Unauthorized,
+		Message:   message,
+		ErrorCode: code,
+	}
+}
+
+// validAPIKey reports whether key matches one of the configured API keys.
+func validAPIKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, sealed := range config.APIKeys {
+		expected, err := secret.Decrypt(sealed)
+		if err == nil && subtle.ConstantTimeCompare([]byte(key), []byte(expected)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// callerOf returns the caller Authorize identified.
+func callerOf(req sdk.APIRequest) *Caller {
+	caller, _ := req.GetAttribute(callerAttribute).(*Caller)
+	return caller
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"example.com/micro/config"
+	"example.com/micro/secret"
+	"github.com/dgrijalva/jwt-go"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAudience = "https://autologin.example.com"
+
+// setupAccess serves the admin API with tokens signed by key accepted.
+func setupAccess(t *testing.T, key *rsa.PrivateKey) http.Handler {
+	t.Helper()
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "test",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config.Access = &config.AccessConfig{
+		Audience: testAudience,
+		JWKS:     path,
+		Bindings: map[string][]string{
+			"admin":    {"user:lead@example.com"},
+			"operator": {"user:qa@example.com"},
+			"viewer":   {"domain:example.com"},
+		},
+	}
+	t.Cleanup(func() { config.Access = nil })
+	if err := InitAccess(); err != nil {
+		t.Fatalf("InitAccess: %v", err)
+	}
+
+	server, _ := sdk.NewApp("test").SetupAPIServer("HTTP")
+	if err := SetRoutes(server); err != nil {
+		t.Fatalf("SetRoutes: %v", err)
+	}
+	return server.(*sdk.HTTPAPIServer)
+}
+
+func idToken(t *testing.T, key *rsa.PrivateKey, email string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            "https://accounts.google.com",
+		"aud":            testAudience,
+		"email":          email,
+		"email_verified": true,
+		"hd":             email[strings.LastIndex(email, "@")+1:],
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = "test"
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestAuthorize(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	stranger, _ := rsa.GenerateKey(rand.Reader, 2048)
+	handler := setupAccess(t, key)
+
+	t.Setenv("MASTER_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err := secret.Init(); err != nil {
+		t.Fatalf("secret.Init: %v", err)
+	}
+	sealed, err := secret.Encrypt("tooling-key")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	config.APIKeys = []string{sealed}
+	t.Cleanup(func() { config.APIKeys = nil })
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		bearer string
+		apiKey string
+		want   int
+	}{
+		{"no credentials", "GET", "/runs", "", "", http.StatusUnauthorized},
+		{"foreign signature", "GET", "/runs", idToken(t, stranger, "lead@example.com"), "", http.StatusUnauthorized},
+		{"no role", "GET", "/runs", idToken(t, key, "someone@example.org"), "", http.StatusForbidden},
+		{"viewer reads", "GET", "/runs", idToken(t, key, "dev@example.com"), "", 0},
+		{"viewer triggers", "POST", "/runs", idToken(t, key, "dev@example.com"), "", http.StatusForbidden},
+		{"viewer disables account", "PUT", "/accounts/1/disable", idToken(t, key, "dev@example.com"), "", http.StatusForbidden},
+		{"operator disables account", "PUT", "/accounts/1/disable", idToken(t, key, "qa@example.com"), "", 0},
+		{"operator edits account", "PUT", "/accounts/1", idToken(t, key, "qa@example.com"), "", http.StatusForbidden},
+		{"operator edits schedule", "PUT", "/schedules/AUTO_LOGIN/pause", idToken(t, key, "qa@example.com"), "", http.StatusForbidden},
+		{"admin edits schedule", "PUT", "/schedules/AUTO_LOGIN/pause", idToken(t, key, "lead@example.com"), "", 0},
+		{"unknown route", "GET", "/unknown", idToken(t, key, "qa@example.com"), "", http.StatusForbidden},
+		{"wrong API key", "GET", "/tokens/stg/qa.stg", "", "other-key", http.StatusUnauthorized},
+		{"API key gets token", "GET", "/tokens/stg/qa.stg", "", "tooling-key", 0},
+		{"API key triggers", "POST", "/runs", "", "tooling-key", http.StatusForbidden},
+		{"API key takes lease", "POST", "/leases", "", "tooling-key", http.StatusForbidden},
+		{"API key disables account", "PUT", "/accounts/1/disable", "", "tooling-key", http.StatusForbidden},
+		{"metrics scrape", "GET", "/metrics", "", "", http.StatusOK},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		if c.bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+c.bearer)
+		}
+		if c.apiKey != "" {
+			req.Header.Set("X-API-Key", c.apiKey)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		denied := rec.Code == http.StatusUnauthorized || rec.Code == http.StatusForbidden
+		if c.want != 0 && rec.Code != c.want {
+			t.Errorf("%s: HTTP %d, want %d", c.name, rec.Code, c.want)
+		}
+		if c.want == 0 && denied {
+			t.Errorf("%s: HTTP %d, want the request let through: %s", c.name, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestInitAccessRefusesOpenAPI(t *testing.T) {
+	config.Access = nil
+	if err := InitAccess(); err == nil {
+		t.Error("InitAccess accepted a missing access file without INSECURE_DEV")
+	}
+}
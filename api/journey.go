@@ -0,0 +1,24 @@
+package api
+
+import (
+	"example.com/micro/action"
+	"example.com/micro/model"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk"
+)
+
+// JourneyRunList GET /journey-runs
+func JourneyRunList(req sdk.APIRequest, resp sdk.APIResponder) error {
+	filter := &model.JourneyRun{
+		Journey:     req.GetParam("journey"),
+		Environment: req.GetParam("environment"),
+		Status:      req.GetParam("status"),
+	}
+	offset := sdk.ParseInt64(req.GetParam("offset"), 0)
+	limit := sdk.ParseInt64(req.GetParam("limit"), 20)
+	return resp.Respond(action.GetJourneyRunList(filter, offset, limit))
+}
+
+// JourneyRun POST /journeys/:name/run
+func JourneyRun(req sdk.APIRequest, resp sdk.APIResponder) error {
+	return resp.Respond(action.RunJourneyByName(req.GetVar("name")))
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"example.com/micro/action"
+	"example.com/micro/model"
+	"example.com/micro/model/core/obj"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk"
+	"strings"
+)
+
+// LeaseCreate POST /leases
+func LeaseCreate(req sdk.APIRequest, resp sdk.APIResponder) error {
+	var input action.LeaseRequest
+	if err := req.GetContent(&input); err != nil {
+		return resp.Respond(obj.WithInvalidInput(err))
+	}
+	// People lease for themselves by default.
+	if caller := callerOf(req); input.Holder == "" && caller != nil && strings.Contains(caller.Name, "@") {
+		input.Holder = caller.Name
+	}
+	return resp.Respond(action.AcquireLease(&input))
+}
+
+// LeaseList GET /leases
+func LeaseList(req sdk.APIRequest, resp sdk.APIResponder) error {
+	filter := &model.Lease{
+		Username:   req.GetParam("username"),
+		DomainType: req.GetParam("domainType"),
+		Holder:     req.GetParam("holder"),
+		Status:     req.GetParam("status"),
+	}
+	offset := sdk.ParseInt64(req.GetParam("offset"), 0)
+	limit := sdk.ParseInt64(req.GetParam("limit"), 20)
+	return resp.Respond(action.GetLeaseList(filter, offset, limit))
+}
+
+// LeaseRenew PUT /leases/:leaseId/renew
+func LeaseRenew(req sdk.APIRequest, resp sdk.APIResponder) error {
+	var input struct {
+		TTLSeconds int `json:"ttlSeconds,omitempty"`
+	}
+	if err := optionalContent(req, &input); err != nil {
+		return resp.Respond(obj.WithInvalidInput(err))
+	}
+	return resp.Respond(action.RenewLease(req.GetVar("leaseId"), input.TTLSeconds))
+}
+
+// LeaseRelease DELETE /leases/:leaseId
+func LeaseRelease(req sdk.APIRequest, resp sdk.APIResponder) error {
+	return resp.Respond(action.ReleaseLease(req.GetVar("leaseId")))
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"example.com/micro/action"
+	"example.com/micro/model"
+	"example.com/micro/model/core/obj"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk"
+	"strings"
+)
+
+// RunCreate POST /runs
+func RunCreate(req sdk.APIRequest, resp sdk.APIResponder) error {
+	var input struct {
+		Environment string `json:"environment,omitempty"`
+		Username    string `json:"username,omitempty"`
+		Type        string `json:"type,omitempty"`
+	}
+	if err := optionalContent(req, &input); err != nil {
+		return resp.Respond(obj.WithInvalidInput(err))
+	}
+	return resp.Respond(action.TriggerRun(action.RunOption{
+		Trigger:     model.RunTrigger.API,
+		Environment: input.Environment,
+		Username:    input.Username,
+		Type:        input.Type,
+	}))
+}
+
+// optionalContent decodes the body into v, if there is one.
+func optionalContent(req sdk.APIRequest, v interface{}) error {
+	if strings.TrimSpace(req.GetContentText()) == "" {
+		return nil
+	}
+	return req.GetContent(v)
+}
+
+// RunList GET /runs
+func RunList(req sdk.APIRequest, resp sdk.APIResponder) error {
+	filter := &model.LoginRun{
+		Trigger:     req.GetParam("trigger"),
+		Environment: req.GetParam("environment"),
+		Status:      req.GetParam("status"),
+	}
+	offset := sdk.ParseInt64(req.GetParam("offset"), 0)
+	limit := sdk.ParseInt64(req.GetParam("limit"), 20)
+	return resp.Respond(action.GetRunList(filter, offset, limit))
+}
+
+// RunGet GET /runs/:runId
+func RunGet(req sdk.APIRequest, resp sdk.APIResponder) error {
+	return resp.Respond(action.GetRun(req.GetVar("runId")))
+}
+
+// AttemptList GET /attempts?domainType=&username=
+func AttemptList(req sdk.APIRequest, resp sdk.APIResponder) error {
+	offset := sdk.ParseInt64(req.GetParam("offset"), 0)
+	limit := sdk.ParseInt64(req.GetParam("limit"), 50)
+	return resp.Respond(action.GetAccountAttempts(req.GetParam("domainType"), req.GetParam("username"), offset, limit))
+}
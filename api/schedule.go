@@ -0,0 +1,32 @@
+package api
+
+import (
+	"example.com/micro/action"
+	"example.com/micro/model"
+	"example.com/micro/model/core/obj"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk"
+)
+
+// ScheduleUpdate PUT /schedules/:topic
+func ScheduleUpdate(req sdk.APIRequest, resp sdk.APIResponder) error {
+	var input model.Schedule
+	if err := req.GetContent(&input); err != nil {
+		return resp.Respond(obj.WithInvalidInput(err))
+	}
+	return resp.Respond(action.UpdateSchedule(req.GetVar("topic"), &input))
+}
+
+// ScheduleList GET /schedules
+func ScheduleList(req sdk.APIRequest, resp sdk.APIResponder) error {
+	return resp.Respond(action.GetScheduleList())
+}
+
+// SchedulePause PUT /schedules/:topic/pause
+func SchedulePause(req sdk.APIRequest, resp sdk.APIResponder) error {
+	return resp.Respond(action.PauseSchedule(req.GetVar("topic")))
+}
+
+// ScheduleResume PUT /schedules/:topic/resume
+func ScheduleResume(req sdk.APIRequest, resp sdk.APIResponder) error {
+	return resp.Respond(action.ResumeSchedule(req.GetVar("topic")))
+}
@@ -0,0 +1,11 @@
+package api
+
+import (
+	"example.com/micro/action"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk"
+)
+
+// TokenGet GET /tokens/:env/:username
+func TokenGet(req sdk.APIRequest, resp sdk.APIResponder) error {
+	return resp.Respond(action.GetSessionToken(req.GetVar("env"), req.GetVar("username")))
+}
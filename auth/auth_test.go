@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const testAudience = "https://autologin.example.com"
+
+// writeJWKS publishes the public part of key under kid in a local JWKS file.
+func writeJWKS(t *testing.T, kid string, key *rsa.PrivateKey) string {
+	t.Helper()
+	content, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": kid,
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func sign(t *testing.T, kid string, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            "https://accounts.google.com",
+		"aud":            testAudience,
+		"sub":            "1234567890",
+		"email":          "QA.Lead@example.com",
+		"email_verified": true,
+		"hd":             "Example.com",
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestVerify(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	v := &Verifier{Audience: testAudience, Issuers: GoogleIssuers, Keys: NewJWKS(writeJWKS(t, "k1", key))}
+
+	id, err := v.Verify(sign(t, "k1", key, validClaims()))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if id.Email != "qa.lead@example.com" || id.HostedDomain != "example.com" || id.Subject != "1234567890" {
+		t.Errorf("Verify = %+v, want the lower-cased email and domain and the subject", id)
+	}
+
+	listed := validClaims()
+	listed["aud"] = []interface{}{"other", testAudience}
+	if _, err = v.Verify(sign(t, "k1", key, listed)); err != nil {
+		t.Errorf("Verify with aud list: %v", err)
+	}
+
+	unverified := validClaims()
+	unverified["email_verified"] = false
+	if id, err = v.Verify(sign(t, "k1", key, unverified)); err != nil || id.Email != "" || id.HostedDomain != "" {
+		t.Errorf("Verify with unverified email = %+v, %v; want no email nor domain", id, err)
+	}
+
+	refused := map[string]string{}
+	audience := validClaims()
+	audience["aud"] = "https://other.example.com"
+	refused["audience"] = sign(t, "k1", key, audience)
+	issuer := validClaims()
+	issuer["iss"] = "https://evil.example.com"
+	refused["issuer"] = sign(t, "k1", key, issuer)
+	expired := validClaims()
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+	refused["expired"] = sign(t, "k1", key, expired)
+	noExpiry := validClaims()
+	delete(noExpiry, "exp")
+	refused["no expiry"] = sign(t, "k1", key, noExpiry)
+	refused["wrong key"] = sign(t, "k1", other, validClaims())
+	refused["unknown kid"] = sign(t, "k2", key, validClaims())
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims())
+	hmac.Header["kid"] = "k1"
+	refused["HS256"], _ = hmac.SignedString([]byte("secret"))
+	refused["garbage"] = "not-a-token"
+
+	for name, raw := range refused {
+		if _, err = v.Verify(raw); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Verify(%s): err = %v, want %v", name, err, ErrInvalidToken)
+		}
+	}
+}
+
+func TestParseJWKSRejectsEmptySet(t *testing.T) {
+	if _, err := ParseJWKS([]byte(`{"keys": [{"kty": "EC", "kid": "x"}]}`)); err == nil {
+		t.Error("ParseJWKS accepted a set without RSA key")
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	p, err := NewPolicy(map[string][]string{
+		"admin":    {"user:Lead@example.com"},
+		"operator": {"group:qa-operators"},
+		"viewer":   {"domain:example.com", "user:lead@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+
+	cases := []struct {
+		id   *Identity
+		want Role
+	}{
+		{&Identity{Email: "lead@example.com"}, Admin},
+		{&Identity{Email: "dev@example.com", Groups: []string{"qa-operators"}}, Operator},
+		{&Identity{Email: "dev@example.com", HostedDomain: "example.com"}, Viewer},
+		{&Identity{Email: "dev@example.com"}, None},
+		{&Identity{Email: "dev@example.org", HostedDomain: "example.org"}, None},
+		{&Identity{Email: "dev@notexample.com", HostedDomain: "notexample.com"}, None},
+		{&Identity{Subject: "robot"}, None},
+	}
+	for _, c := range cases {
+		if got := p.RoleOf(c.id); got != c.want {
+			t.Errorf("RoleOf(%+v) = %v, want %v", c.id, got, c.want)
+		}
+	}
+
+	for _, bindings := range []map[string][]string{
+		{"owner": {"user:a@example.com"}},
+		{"viewer": {"a@example.com"}},
+		{"viewer": {"team:qa"}},
+	} {
+		if _, err = NewPolicy(bindings); err == nil {
+			t.Errorf("NewPolicy(%v) accepted an invalid binding", bindings)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GoogleJWKS publishes the keys signing Google ID tokens.
+const GoogleJWKS = "https://www.googleapis.com/oauth2/v3/certs"
+
+const (
+	// jwksMaxAge bounds how long fetched keys are trusted before a refetch.
+	jwksMaxAge = time.Hour
+	// jwksMinRefresh throttles refetches caused by unknown key IDs.
+	jwksMinRefresh = time.Minute
+)
+
+// ErrUnknownKey means no published key has the key ID of the token.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// JWKS resolves token signing keys from a JSON Web Key Set, read from an
+// http(s) URL or a local file. Keys are cached and reloaded hourly, or
+// earlier when a token names a key ID the set does not hold, as happens
+// right after the issuer rotates its keys.
+type JWKS struct {
+	location string
+	client   *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+// NewJWKS returns the key set published at location, a URL or a file path.
+func NewJWKS(location string) *JWKS {
+	return &JWKS{
+		location: location,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Key returns the RSA public key with the given key ID.
+func (s *JWKS) Key(kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[kid]
+	age := time.Since(s.fetched)
+	if ok && age < jwksMaxAge {
+		return key, nil
+	}
+	if !ok && s.keys != nil && age < jwksMinRefresh {
+		return nil, ErrUnknownKey
+	}
+
+	keys, err := s.load()
+	if err != nil {
+		if ok {
+			// Keep trusting the cached key while the source is unreachable.
+			return key, nil
+		}
+		return nil, err
+	}
+	s.keys = keys
+	s.fetched = time.Now()
+
+	if key, ok = keys[kid]; !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+func (s *JWKS) load() (map[string]*rsa.PublicKey, error) {
+	var content []byte
+	var err error
+	if strings.HasPrefix(s.location, "https://") || strings.HasPrefix(s.location, "http://") {
+		content, err = s.fetch()
+	} else {
+		content, err = os.ReadFile(s.location)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load JWKS %s: %w", s.location, err)
+	}
+
+	keys, err := ParseJWKS(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse JWKS %s: %w", s.location, err)
+	}
+	return keys, nil
+}
+
+func (s *JWKS) fetch() ([]byte, error) {
+	resp, err := s.client.Get(s.location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// ParseJWKS decodes the RSA keys of a JSON Web Key Set by key ID. Keys of
+// other types are ignored.
+func ParseJWKS(content []byte) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for i, jwk := range set.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, err := decodeBase64URL(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("key #%d: n: %w", i, err)
+		}
+		e, err := decodeBase64URL(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("key #%d: e: %w", i, err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("key #%d: invalid RSA key", i)
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA key")
+	}
+	return keys, nil
+}
+
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,108 @@
+// Package auth authenticates callers of the admin API with OpenID Connect ID
+// tokens, such as the ones Google issues to users and service accounts, and
+// maps them to roles.
+package auth
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// GoogleIssuers are the issuers of Google ID tokens.
+var GoogleIssuers = []string{"https://accounts.google.com", "accounts.google.com"}
+
+// ErrInvalidToken wraps every reason an ID token is refused.
+var ErrInvalidToken = errors.New("invalid ID token")
+
+// KeySource resolves the public key that signed a token from its key ID.
+type KeySource interface {
+	Key(kid string) (*rsa.PublicKey, error)
+}
+
+// Identity is the caller an ID token vouches for.
+type Identity struct {
+	Subject string
+	Email   string
+	// HostedDomain is the Google Workspace domain of the account, from the
+	// hd claim, empty for consumer accounts whatever their email.
+	HostedDomain string
+	Groups       []string
+}
+
+// Verifier checks RS256 ID tokens: signature against Keys, expiry, issuer
+// and audience.
+type Verifier struct {
+	Audience string
+	Issuers  []string
+	Keys     KeySource
+}
+
+// Verify returns the identity of a valid token.
+func (v *Verifier) Verify(raw string) (*Identity, error) {
+	parser := &jwt.Parser{ValidMethods: []string{jwt.SigningMethodRS256.Alg()}}
+	token, err := parser.Parse(raw, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.Keys.Key(kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	claims := token.Claims.(jwt.MapClaims)
+
+	issuer, _ := claims["iss"].(string)
+	if !contains(v.Issuers, issuer) {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, issuer)
+	}
+	if !hasAudience(claims["aud"], v.Audience) {
+		return nil, fmt.Errorf("%w: audience is not %q", ErrInvalidToken, v.Audience)
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, fmt.Errorf("%w: missing exp", ErrInvalidToken)
+	}
+
+	id := &Identity{}
+	id.Subject, _ = claims["sub"].(string)
+	// An unverified email may belong to anyone, so it grants nothing.
+	if verified, _ := claims["email_verified"].(bool); verified {
+		id.Email, _ = claims["email"].(string)
+		id.Email = strings.ToLower(id.Email)
+		id.HostedDomain, _ = claims["hd"].(string)
+		id.HostedDomain = strings.ToLower(id.HostedDomain)
+	}
+	if groups, ok := claims["groups"].([]interface{}); ok {
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				id.Groups = append(id.Groups, name)
+			}
+		}
+	}
+	return id, nil
+}
+
+// hasAudience accepts the aud claim as a string or a list of strings.
+func hasAudience(aud interface{}, audience string) bool {
+	switch value := aud.(type) {
+	case string:
+		return value == audience
+	case []interface{}:
+		for _, item := range value {
+			if item == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Role is what a caller may do; each role includes the ones below it.
+type Role int
+
+const (
+	// None is the role of callers bound to no role.
+	None Role = iota
+	// Viewer reads accounts, runs, schedules and leases.
+	Viewer
+	// Operator also triggers runs, hands out tokens and leases, and
+	// enables or disables accounts.
+	Operator
+	// Admin also edits accounts and schedules.
+	Admin
+)
+
+var roleNames = map[Role]string{None: "none", Viewer: "viewer", Operator: "operator", Admin: "admin"}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+// ParseRole returns the role named name.
+func ParseRole(name string) (Role, error) {
+	for role, s := range roleNames {
+		if role != None && s == name {
+			return role, nil
+		}
+	}
+	return None, fmt.Errorf("unknown role %q", name)
+}
+
+// Policy grants roles to identities. Members are written "user:<email>",
+// "group:<name>" (matched against the groups claim) or "domain:<domain>"
+// (every account of the Google Workspace domain named by the hd claim of a
+// verified identity; an email suffix is not enough, as anyone can sign in
+// to Google with an address of any domain).
+type Policy struct {
+	members map[string]Role
+}
+
+// NewPolicy builds a policy from role names to members.
+func NewPolicy(bindings map[string][]string) (*Policy, error) {
+	names := make([]string, 0, len(bindings))
+	for name := range bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	p := &Policy{members: make(map[string]Role)}
+	for _, name := range names {
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range bindings[name] {
+			kind, value, ok := strings.Cut(member, ":")
+			if !ok || value == "" || (kind != "user" && kind != "group" && kind != "domain") {
+				return nil, fmt.Errorf("%s: member %q must look like user:, group: or domain:", name, member)
+			}
+			if kind != "group" {
+				member = kind + ":" + strings.ToLower(value)
+			}
+			if role > p.members[member] {
+				p.members[member] = role
+			}
+		}
+	}
+	return p, nil
+}
+
+// RoleOf returns the highest role granted to id.
+func (p *Policy) RoleOf(id *Identity) Role {
+	role := None
+	grant := func(member string) {
+		if r := p.members[member]; r > role {
+			role = r
+		}
+	}
+	if id.Email != "" {
+		grant("user:" + id.Email)
+	}
+	if id.HostedDomain != "" {
+		grant("domain:" + id.HostedDomain)
+	}
+	for _, group := range id.Groups {
+		grant("group:" + group)
+	}
+	return role
+}
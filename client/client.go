@@ -7,6 +7,7 @@ import (
 	sdk_client "gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/client"
 	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/common"
 	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -112,6 +113,7 @@ type Client struct {
 	clientWithoutLog *sdk_client.RestClient
 	clients          map[string]*sdk_client.RestClient
 	headers          map[string]string
+	httpClient       *http.Client
 }
 
 func NewClient(host string, timeout time.Duration) *Client {
@@ -120,8 +122,9 @@ func NewClient(host string, timeout time.Duration) *Client {
 	}
 
 	c := &Client{
-		host:    host,
-		clients: make(map[string]*sdk_client.RestClient),
+		host:       host,
+		clients:    make(map[string]*sdk_client.RestClient),
+		httpClient: &http.Client{Timeout: timeout},
 	}
 	c.clientWithoutLog = sdk_client.NewRESTClient(host, "", timeout, 0, 0)
 	c.clientWithoutLog.AcceptHTTPError(true)
@@ -236,6 +239,9 @@ func (c Client) WithRequest(api string, o APIOption, template interface{}) (erro
 	}
 	m := c.WithMethod(parts[0])
 	result, err := cl.MakeHTTPRequestWithKey(m, o.Headers, o.Params, o.Body, parts[1], &o.Keys)
+	if result == nil {
+		return err, 0
+	}
 	if err != nil {
 		return err, result.Code
 	}
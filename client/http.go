@@ -0,0 +1,77 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Response is a raw HTTP response. Unlike the SDK RestResult it keeps the
+// headers, which callers need for Retry-After and cookies.
+type Response struct {
+	Code   int
+	Header http.Header
+	Body   []byte
+}
+
+// Do sends one request without retrying and returns the raw response.
+// A non-nil error means no response was received. It bypasses the SDK REST
+// clients, so the request is not in their request log.
+func (c *Client) Do(api string, o APIOption) (*Response, error) {
+	path := api
+	for k, v := range o.Vars {
+		path = strings.ReplaceAll(path, ":var_"+k, v)
+	}
+	parts := strings.Split(path, "::")
+	if len(parts) != 2 {
+		return nil, ErrConfiguration
+	}
+
+	target := c.host + parts[1]
+	if o.URL != "" {
+		target = o.URL
+	}
+	if len(o.Params) > 0 {
+		q := url.Values{}
+		for k, v := range o.Params {
+			q.Set(k, v)
+		}
+		target += "?" + q.Encode()
+	}
+
+	var body io.Reader
+	if o.Body != nil {
+		b, err := json.Marshal(o.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(parts[0], target, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range o.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Code:   resp.StatusCode,
+		Header: resp.Header,
+		Body:   content,
+	}, nil
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Lookup follows a dotted path such as "data.0.productId" through decoded
+// JSON; numeric segments index arrays.
+func Lookup(doc interface{}, path string) (interface{}, bool) {
+	current := doc
+	for _, segment := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			v, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			current = v
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			current = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// SameJSON compares values by their JSON encoding, so that 1 and 1.0 or a
+// bson document and a decoded object are equal.
+func SameJSON(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	var na, nb interface{}
+	json.Unmarshal(ja, &na)
+	json.Unmarshal(jb, &nb)
+	ja, _ = json.Marshal(na)
+	jb, _ = json.Marshal(nb)
+	return string(ja) == string(jb)
+}
@@ -0,0 +1,204 @@
+package login
+
+import (
+	"encoding/json"
+	"example.com/micro/client"
+	"example.com/micro/config"
+	"example.com/micro/model"
+	"example.com/micro/ratelimit"
+	"example.com/micro/secret"
+	"example.com/micro/totp"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/common"
+	"time"
+)
+
+var (
+	loginClients = make(map[string]*client.Client)
+	loginLimits  = make(map[string]*ratelimit.Bucket)
+)
+
+// Result is the outcome of one login, after retries. Class is set when the
+// login did not succeed; HTTPCode and Latency describe the last call. Token
+// and TokenExpiry hold the captured session, if the response carried one.
+type Result struct {
+	*common.APIResponse
+	HTTPCode    int
+	Latency     time.Duration
+	Class       string
+	Retries     int
+	Token       string
+	TokenExpiry *time.Time
+
+	// OTPChallenge is set when the login asks for a TOTP code.
+	OTPChallenge string
+
+	retryAfter time.Duration
+}
+
+func loginAPI(env *config.Environment) string {
+	return "POST::" + env.AuthPath
+}
+
+func otpAPI(env *config.Environment) string {
+	return "POST::" + env.OTPPath
+}
+
+// InitLoginClients builds one login client and one rate limit per registered
+// environment.
+func InitLoginClients() {
+	clients := make(map[string]*client.Client, len(config.Environments))
+	limits := make(map[string]*ratelimit.Bucket, len(config.Environments))
+	for name, env := range config.Environments {
+		clients[name] = client.NewClient(env.Domain, -1)
+		limits[name] = ratelimit.NewBucket(env.RateLimit, env.Burst)
+	}
+	loginClients = clients
+	loginLimits = limits
+	Retry.MaxRetries = config.LoginRetries
+}
+
+// IsSupported reports whether a login client exists for the domain type.
+func IsSupported(domainType string) bool {
+	_, ok := loginClients[domainType]
+	return ok
+}
+
+// FuncLogin authenticates against the environment registered as domainType,
+// retrying network errors, timeouts, 5xx and 429 as set by Retry. Every call
+// waits for the environment's rate limit first; Latency excludes that wait.
+// For accounts protected by TOTP the result carries an OTPChallenge instead
+// of a token, to be answered with CompleteOTP.
+func FuncLogin(domainType string, opts ...client.APIOption) *Result {
+	env, cl, o, failure := prepare(domainType, opts...)
+	if failure != nil {
+		return failure
+	}
+	return send(domainType, cl, env, loginAPI(env), func() client.APIOption { return o })
+}
+
+// CompleteOTP answers the challenge of a first login step with the current
+// code of the base32 TOTP seed. The code is generated again for every retry
+// so that a backoff never sends an expired one. Latency and Retries include
+// the first step.
+func CompleteOTP(domainType string, first *Result, seed string) *Result {
+	env, cl, o, failure := prepare(domainType)
+	if failure != nil {
+		return failure
+	}
+	if _, err := totp.DecodeSeed(seed); err != nil {
+		return &Result{APIResponse: &common.APIResponse{
+			Status:    common.APIStatus.Invalid,
+			Message:   err.Error(),
+			ErrorCode: "INVALID_OTP_SEED",
+		}}
+	}
+
+	result := send(domainType, cl, env, otpAPI(env), func() client.APIOption {
+		code, _ := totp.Code(seed, time.Now())
+		o.Body = map[string]interface{}{
+			env.OTPChallengeField: first.OTPChallenge,
+			env.OTPCodeField:      code,
+		}
+		return o
+	})
+	result.Latency += first.Latency
+	result.Retries += first.Retries
+	if result.Status == common.APIStatus.Ok && result.Token == "" && result.OTPChallenge != "" {
+		result.Status = common.APIStatus.Error
+		result.Message = "OTP step answered with another challenge"
+		result.ErrorCode = "INVALID_RESPONSE"
+		result.Class = model.FailureClass.ClientError
+	}
+	return result
+}
+
+// prepare resolves the environment and client of domainType and fills the
+// default headers of the login calls.
+func prepare(domainType string, opts ...client.APIOption) (*config.Environment, *client.Client, client.APIOption, *Result) {
+	env, ok := config.GetEnvironment(domainType)
+	cl := loginClients[domainType]
+	if !ok || cl == nil {
+		return nil, nil, client.APIOption{}, &Result{APIResponse: &common.APIResponse{
+			Status:    common.APIStatus.Invalid,
+			Message:   "Unknown domain type " + domainType,
+			ErrorCode: "UNKNOWN_DOMAIN_TYPE",
+		}}
+	}
+
+	o := cl.WithAPIOption(opts...)
+	if o.Headers == nil || len(o.Headers) == 0 {
+		authorization, err := secret.Decrypt(env.Authorization)
+		if err != nil {
+			return nil, nil, o, &Result{APIResponse: &common.APIResponse{
+				Status:    common.APIStatus.Error,
+				Message:   "Cannot decrypt authorization of " + domainType + ": " + err.Error(),
+				ErrorCode: "INVALID_ENDPOINT_CONFIGURATION",
+			}}
+		}
+		o.Headers["Content-Type"] = "application/json"
+		if authorization != "" {
+			o.Headers["Authorization"] = authorization
+		}
+	}
+	return env, cl, o, nil
+}
+
+// send calls api until it succeeds, fails for good or runs out of retries.
+func send(domainType string, cl *client.Client, env *config.Environment, api string, option func() client.APIOption) *Result {
+	for retries := 0; ; retries++ {
+		loginLimits[domainType].Wait()
+		result := funcLogin(cl, env, api, option())
+		result.Retries = retries
+		if !retryable(result.Class) || retries >= Retry.MaxRetries {
+			return result
+		}
+		time.Sleep(Retry.delay(retries, result.retryAfter))
+	}
+}
+
+func funcLogin(cl *client.Client, env *config.Environment, api string, o client.APIOption) *Result {
+	start := time.Now()
+	resp, err := cl.Do(api, o)
+	latency := time.Since(start)
+
+	if err == client.ErrConfiguration {
+		return &Result{APIResponse: &common.APIResponse{
+			Status:    common.APIStatus.Error,
+			Message:   "Invalid endpoint configuration",
+			ErrorCode: "INVALID_ENDPOINT_CONFIGURATION",
+		}}
+	}
+
+	if err != nil {
+		return &Result{
+			APIResponse: &common.APIResponse{
+				Status:    common.APIStatus.Error,
+				Message:   err.Error(),
+				ErrorCode: "N0_RESPONSE",
+			},
+			Latency: latency,
+			Class:   classifyError(err),
+		}
+	}
+
+	result := &Result{
+		APIResponse: &common.APIResponse{},
+		HTTPCode:    resp.Code,
+		Latency:     latency,
+		retryAfter:  parseRetryAfter(resp.Header, time.Now()),
+	}
+	if err = json.Unmarshal(resp.Body, result.APIResponse); err != nil || result.Status == "" {
+		result.Status = cl.WithStatus(resp.Code)
+		result.Message = "Unexpected response body"
+		result.ErrorCode = "INVALID_RESPONSE"
+	}
+	if result.Status != common.APIStatus.Ok || resp.Code/100 != 2 {
+		result.Class = classify(resp.Code, result.ErrorCode)
+		return result
+	}
+	result.Token, result.TokenExpiry = extractToken(env, result.Data)
+	if result.Token == "" {
+		result.OTPChallenge = extractChallenge(env, result.Data)
+	}
+	return result
+}
@@ -0,0 +1,199 @@
+package login
+
+import (
+	"example.com/micro/client"
+	"example.com/micro/config"
+	"example.com/micro/model"
+	"example.com/micro/secret"
+	"github.com/dgrijalva/jwt-go"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/common"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useServer registers a "test" environment pointing at handler.
+func useServer(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	t.Setenv("INSECURE_DEV", "true")
+	if err := secret.Init(); err != nil {
+		t.Fatalf("secret.Init: %v", err)
+	}
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	saved, savedRetry := config.Environments, Retry
+	t.Cleanup(func() {
+		config.Environments, Retry = saved, savedRetry
+		InitLoginClients()
+	})
+	config.Environments = map[string]*config.Environment{
+		"test": {
+			Name:             "test",
+			Domain:           srv.URL,
+			AuthPath:         config.DefaultAuthPath,
+			RateLimit:        -1,
+			TokenField:       config.DefaultTokenField,
+			TokenExpiryField: config.DefaultTokenExpiryField,
+		},
+	}
+	InitLoginClients()
+	Retry = RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+}
+
+func body() client.APIOption {
+	return client.APIOption{Body: map[string]interface{}{"username": "u", "password": "p"}}
+}
+
+func TestFuncLoginRetriesServerErrors(t *testing.T) {
+	var calls int32
+	useServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":"OK","message":"Login successfully"}`))
+	})
+
+	result := FuncLogin("test", body())
+	if result.Status != common.APIStatus.Ok || result.Retries != 2 || result.Class != "" {
+		t.Fatalf("got status %s, retries %d, class %q; want OK after 2 retries", result.Status, result.Retries, result.Class)
+	}
+}
+
+func TestFuncLoginNeverRetriesUnauthorized(t *testing.T) {
+	var calls int32
+	useServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"status":"UNAUTHORIZED","errorCode":"WRONG_PASSWORD"}`))
+	})
+
+	result := FuncLogin("test", body())
+	if calls != 1 || result.Retries != 0 {
+		t.Fatalf("got %d calls, %d retries; want a single call", calls, result.Retries)
+	}
+	if result.Class != model.FailureClass.BadCredentials || result.HTTPCode != 401 {
+		t.Fatalf("got class %q, code %d; want BAD_CREDENTIALS, 401", result.Class, result.HTTPCode)
+	}
+}
+
+func TestFuncLoginGivesUpAfterMaxRetries(t *testing.T) {
+	var calls int32
+	useServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	result := FuncLogin("test", body())
+	if calls != 3 || result.Class != model.FailureClass.RateLimited {
+		t.Fatalf("got %d calls, class %q; want 3 calls, RATE_LIMITED", calls, result.Class)
+	}
+}
+
+func TestFuncLoginCapturesToken(t *testing.T) {
+	useServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"OK","data":[{"bearerToken":"tok-1","expiredTime":"2030-01-02T03:04:05Z"}]}`))
+	})
+
+	result := FuncLogin("test", body())
+	want := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	if result.Token != "tok-1" || result.TokenExpiry == nil || !result.TokenExpiry.Equal(want) {
+		t.Fatalf("got token %q expiring %v, want tok-1 expiring %v", result.Token, result.TokenExpiry, want)
+	}
+}
+
+func TestFuncLoginReadsJWTExpiry(t *testing.T) {
+	exp := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"exp": exp.Unix()}).SignedString([]byte("key"))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	useServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"OK","data":[{"bearerToken":"` + token + `","expiredTime":"2030-01-02T03:04:05Z"}]}`))
+	})
+
+	result := FuncLogin("test", body())
+	if result.TokenExpiry == nil || !result.TokenExpiry.Equal(exp) {
+		t.Fatalf("got expiry %v, want the exp claim %v", result.TokenExpiry, exp)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	useServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"status":"OK","data":[{"isActive":true,"level":2,"name":"QA"}]}`))
+	})
+
+	tests := []struct {
+		probe   *model.Probe
+		wantErr bool
+	}{
+		{&model.Probe{API: "GET::/me", Assert: map[string]interface{}{"data.0.isActive": true, "data.0.level": 2}}, false},
+		{&model.Probe{API: "GET::/me", Assert: map[string]interface{}{"data.0.isActive": false}}, true},
+		{&model.Probe{API: "GET::/me", Assert: map[string]interface{}{"data.1.name": "QA"}}, true},
+		{&model.Probe{API: "GET::/me", ExpectStatus: "NOT_FOUND"}, true},
+		{&model.Probe{API: "GET::/me", ExpectCode: 201}, true},
+	}
+	for i, tt := range tests {
+		if err := Verify("test", "tok-1", tt.probe); (err != nil) != tt.wantErr {
+			t.Errorf("probe #%d: err = %v, wantErr %v", i, err, tt.wantErr)
+		}
+	}
+	if err := Verify("test", "other", &model.Probe{API: "GET::/me"}); err == nil {
+		t.Error("probe with a wrong token succeeded")
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		code      int
+		errorCode string
+		want      string
+	}{
+		{401, "WRONG_PASSWORD", model.FailureClass.BadCredentials},
+		{403, "", model.FailureClass.BadCredentials},
+		{403, "ACCOUNT_LOCKED", model.FailureClass.Locked},
+		{423, "", model.FailureClass.Locked},
+		{400, "INVALID_PASSWORD", model.FailureClass.BadCredentials},
+		{429, "", model.FailureClass.RateLimited},
+		{502, "", model.FailureClass.ServerError},
+		{504, "", model.FailureClass.Timeout},
+		{404, "NOT_FOUND", model.FailureClass.ClientError},
+		{400, "USER_LOCKED", model.FailureClass.Locked},
+		{500, "DEADLOCK", model.FailureClass.ServerError},
+		{503, "LOCK_TIMEOUT", model.FailureClass.ServerError},
+		{409, "LOCK_TIMEOUT", model.FailureClass.ClientError},
+	}
+	for _, tt := range tests {
+		if got := classify(tt.code, tt.errorCode); got != tt.want {
+			t.Errorf("classify(%d, %q) = %q, want %q", tt.code, tt.errorCode, got, tt.want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	header := http.Header{}
+	header.Set("Retry-After", now.Add(5*time.Second).Format(http.TimeFormat))
+	if got := parseRetryAfter(header, now); got != 5*time.Second {
+		t.Fatalf("parseRetryAfter(date) = %v, want 5s", got)
+	}
+
+	p := RetryPolicy{MaxRetries: 5, BaseDelay: time.Second, MaxDelay: 4 * time.Second}
+	if got := p.delay(0, time.Minute); got != 4*time.Second {
+		t.Fatalf("Retry-After beyond MaxDelay gives %v, want 4s", got)
+	}
+	for retry, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		if got := p.delay(retry, 0); got < max/2 || got > max {
+			t.Fatalf("delay(%d) = %v, want within [%v, %v]", retry, got, max/2, max)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package login
+
+import (
+	"encoding/json"
+	"example.com/micro/client"
+	"example.com/micro/model"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// Verify sends probe to the environment of domainType with the session token
+// as bearer and checks the response against its expectations.
+func Verify(domainType, token string, probe *model.Probe) error {
+	cl := loginClients[domainType]
+	if cl == nil {
+		return fmt.Errorf("unknown domain type %s", domainType)
+	}
+
+	o := cl.WithAPIOption(client.APIOption{
+		Headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Authorization": "Bearer " + token,
+		},
+		Params: probe.Params,
+		Body:   probe.Body,
+	})
+	resp, err := cl.Do(probe.API, o)
+	if err != nil {
+		return err
+	}
+
+	expectCode := probe.ExpectCode
+	if expectCode == 0 {
+		expectCode = http.StatusOK
+	}
+	if resp.Code != expectCode {
+		return fmt.Errorf("HTTP %d, want %d", resp.Code, expectCode)
+	}
+
+	var doc interface{}
+	if err = json.Unmarshal(resp.Body, &doc); err != nil {
+		if probe.Assert == nil && probe.ExpectStatus == "" {
+			return nil
+		}
+		return fmt.Errorf("response is not JSON: %v", err)
+	}
+
+	expectStatus := probe.ExpectStatus
+	if expectStatus == "" {
+		expectStatus = "OK"
+	}
+	if status, ok := client.Lookup(doc, "status"); ok && status != expectStatus {
+		return fmt.Errorf("status %v, want %s", status, expectStatus)
+	}
+
+	paths := make([]string, 0, len(probe.Assert))
+	for path := range probe.Assert {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		actual, ok := client.Lookup(doc, path)
+		if !ok {
+			return fmt.Errorf("%s is missing", path)
+		}
+		if !client.SameJSON(actual, probe.Assert[path]) {
+			return fmt.Errorf("%s is %v, want %v", path, actual, probe.Assert[path])
+		}
+	}
+	return nil
+}
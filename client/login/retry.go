@@ -0,0 +1,114 @@
+package login
+
+import (
+	"errors"
+	"example.com/micro/model"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy bounds how a login call is retried. Delays grow exponentially
+// from BaseDelay, with jitter, up to MaxDelay; a Retry-After header from the
+// server takes precedence but is capped at MaxDelay as well.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+var Retry = RetryPolicy{
+	MaxRetries: 2,
+	BaseDelay:  500 * time.Millisecond,
+	MaxDelay:   30 * time.Second,
+}
+
+func (p RetryPolicy) delay(retry int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		if retryAfter > p.MaxDelay {
+			return p.MaxDelay
+		}
+		return retryAfter
+	}
+
+	d := p.BaseDelay
+	for i := 0; i < retry && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryable reports whether another call may succeed. Bad credentials and
+// locked accounts are never retried: each attempt counts against the
+// account's lockout threshold.
+func retryable(class string) bool {
+	switch class {
+	case model.FailureClass.Network, model.FailureClass.Timeout,
+		model.FailureClass.ServerError, model.FailureClass.RateLimited:
+		return true
+	}
+	return false
+}
+
+// classify derives a failure class from the HTTP code and the ErrorCode of
+// the response body. The status class comes first: a 5xx is an outage, and
+// retried, even when its code reads like DEADLOCK or LOCK_TIMEOUT. Only codes
+// such as ACCOUNT_LOCKED mark a locked account.
+func classify(code int, errorCode string) string {
+	ec := strings.ToUpper(errorCode)
+	switch {
+	case code == http.StatusLocked:
+		return model.FailureClass.Locked
+	case code == http.StatusTooManyRequests:
+		return model.FailureClass.RateLimited
+	case code == http.StatusRequestTimeout || code == http.StatusGatewayTimeout:
+		return model.FailureClass.Timeout
+	case code >= 500:
+		return model.FailureClass.ServerError
+	case strings.HasSuffix(ec, "LOCKED"):
+		return model.FailureClass.Locked
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return model.FailureClass.BadCredentials
+	case strings.Contains(ec, "PASSWORD") || strings.Contains(ec, "CREDENTIAL") ||
+		strings.Contains(ec, "WRONG") || strings.Contains(ec, "UNAUTHORIZED"):
+		return model.FailureClass.BadCredentials
+	}
+	return model.FailureClass.ClientError
+}
+
+// classifyError tells timeouts from other transport failures.
+func classifyError(err error) string {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return model.FailureClass.Timeout
+	}
+	return model.FailureClass.Network
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date.
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	v := strings.TrimSpace(header.Get("Retry-After"))
+	if v == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+package login
+
+import (
+	"encoding/json"
+	"example.com/micro/config"
+	"github.com/dgrijalva/jwt-go"
+	"time"
+)
+
+// extractToken reads the session token and its expiry from the first item of
+// a successful login response, using the field names of the environment.
+// The exp claim of a JWT token wins over the expiry field.
+func extractToken(env *config.Environment, data interface{}) (string, *time.Time) {
+	item := firstItem(data)
+	token, _ := item[env.TokenField].(string)
+	if token == "" {
+		return "", nil
+	}
+	if exp := jwtExpiry(token); exp != nil {
+		return token, exp
+	}
+	return token, parseExpiry(item[env.TokenExpiryField])
+}
+
+// extractChallenge reads the OTP challenge of a login response that asks for
+// a second step.
+func extractChallenge(env *config.Environment, data interface{}) string {
+	challenge, _ := firstItem(data)[env.OTPChallengeField].(string)
+	return challenge
+}
+
+func firstItem(data interface{}) map[string]interface{} {
+	items, ok := data.([]interface{})
+	if !ok || len(items) == 0 {
+		return nil
+	}
+	item, _ := items[0].(map[string]interface{})
+	return item
+}
+
+// jwtExpiry returns the exp claim of a JWT token. The signature is not
+// verified: the token comes straight from the auth service and is only used
+// to plan its refresh.
+func jwtExpiry(token string) *time.Time {
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(token, claims); err != nil {
+		return nil
+	}
+	switch exp := claims["exp"].(type) {
+	case float64:
+		return parseExpiry(exp)
+	case json.Number:
+		v, err := exp.Float64()
+		if err != nil {
+			return nil
+		}
+		return parseExpiry(v)
+	}
+	return nil
+}
+
+// parseExpiry accepts an RFC 3339 time or a Unix time in seconds or
+// milliseconds.
+func parseExpiry(v interface{}) *time.Time {
+	var t time.Time
+	switch value := v.(type) {
+	case string:
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil
+		}
+		t = parsed
+	case float64:
+		if value <= 0 {
+			return nil
+		}
+		if value > 1e12 {
+			t = time.UnixMilli(int64(value))
+		} else {
+			t = time.Unix(int64(value), 0)
+		}
+	default:
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"example.com/micro/action"
+	"example.com/micro/config"
+	"example.com/micro/fakeauth"
+	"example.com/micro/model"
+	"example.com/micro/secret"
+	"flag"
+	"fmt"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Exit codes of the process, so that Cloud Run Jobs and schedulers tell a
+// run with failed accounts from a setup that cannot run at all.
+const (
+	exitSuccess = 0
+	exitPartial = 1  // some accounts failed to log in, others did not
+	exitFailed  = 3  // every account failed, or the run failed as a whole or could not start
+	exitConfig  = 78 // EX_CONFIG, bad input or configuration, nothing was run; Go panics exit with 2
+)
+
+// exit logs v and ends the process with code, in place of log.Fatal which
+// always exits with 1.
+func exit(code int, v ...interface{}) {
+	log.Print(v...)
+	os.Exit(code)
+}
+
+// runCommand executes a one-off operator command instead of the service.
+func runCommand(name string, args []string) {
+	switch name {
+	case "run-once":
+		runOnceCommand()
+	case "run":
+		runFilteredCommand(args)
+	case "test":
+		testCommand(args)
+	case "list":
+		listCommand(args)
+	case "validate":
+		validateCommand(args)
+	case "encrypt":
+		encryptCommand()
+	case "reencrypt":
+		reencryptCommand()
+	case "fake-auth":
+		fakeAuthCommand(args)
+	default:
+		exit(exitConfig, fmt.Sprintf("Unknown command %q (available: run-once, run, test, list, validate, encrypt, reencrypt, fake-auth)", name))
+	}
+}
+
+// withDatabase loads the configuration of a login run, connects and prepares
+// the database as the service does, then calls fn.
+func withDatabase(fn func()) {
+	if err := loadRunConfig(); err != nil {
+		exit(exitConfig, "Error when loading configuration: ", err)
+	}
+	dbConfig, err := config.LoadDatabase()
+	if err != nil {
+		exit(exitConfig, "Error when loading database configuration: ", err)
+	}
+
+	client := app.SetupDBClient(dbConfig, func(database *mongo.Database) error {
+		if err := initDatabase(database); err != nil {
+			exit(exitConfig, err)
+		}
+		fn()
+		return nil
+	})
+	if err = client.Connect(); err != nil {
+		exit(exitFailed, "Error when connecting database: ", err)
+	}
+}
+
+// runOnceCommand executes one login run over every active account, prints
+// its report and exits with its outcome, for Cloud Run Jobs and schedulers
+// that start a container per run instead of keeping the service up.
+func runOnceCommand() {
+	runAndExit(action.RunOption{Trigger: model.RunTrigger.Job})
+}
+
+// runFilteredCommand executes one login run restricted by its flags, e.g.
+// run -env stg -type EMPLOYEE.
+func runFilteredCommand(args []string) {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	env := flags.String("env", "", "environment of the accounts, all when empty")
+	accountType := flags.String("type", "", "type of the accounts, all when empty")
+	username := flags.String("username", "", "one account of -env")
+	parseFlags(flags, args)
+	if *username != "" && *env == "" {
+		exit(exitConfig, "-env is required with -username")
+	}
+
+	runAndExit(action.RunOption{
+		Trigger:     model.RunTrigger.CLI,
+		Environment: *env,
+		Username:    *username,
+		Type:        *accountType,
+	})
+}
+
+// runAndExit executes one login run and exits with its outcome.
+func runAndExit(opt action.RunOption) {
+	withDatabase(func() {
+		if _, ok := config.GetEnvironment(opt.Environment); opt.Environment != "" && !ok {
+			exit(exitConfig, fmt.Sprintf("Unknown environment %q", opt.Environment))
+		}
+		run, err := action.RunAutoLogin(opt)
+		if err != nil {
+			exit(exitFailed, "Run skipped: ", err)
+		}
+		os.Exit(runExitCode(run))
+	})
+}
+
+// testCommand logs one registered account in, whatever its status, and
+// prints the classified result with its timing. The attempt is recorded and
+// counts against the account like the ones of a run.
+func testCommand(args []string) {
+	if len(args) != 2 {
+		exit(exitConfig, "Usage: test <environment> <username>")
+	}
+	withDatabase(func() {
+		account, err := action.GetAccount(args[0], args[1])
+		if err != nil {
+			exit(exitConfig, err)
+		}
+
+		start := time.Now()
+		attempt := action.TryLogin(account)
+		fmt.Print(formatAttempt(attempt, time.Since(start)))
+		switch attempt.Status {
+		case model.AttemptStatus.Success:
+			os.Exit(exitSuccess)
+		case model.AttemptStatus.ConfigError:
+			os.Exit(exitConfig)
+		default:
+			os.Exit(exitFailed)
+		}
+	})
+}
+
+// formatAttempt describes a login attempt on a few lines, e.g.
+//
+//	stg/alice: FAILED (BAD_CREDENTIALS) in 412ms, login 398ms, 0 retries
+//	HTTP 401 WRONG_PASSWORD: wrong username or password
+func formatAttempt(attempt *model.LoginAttempt, elapsed time.Duration) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s/%s: %s", attempt.DomainType, attempt.Username, attempt.Status)
+	if attempt.Class != "" {
+		fmt.Fprintf(&b, " (%s)", attempt.Class)
+	}
+	fmt.Fprintf(&b, " in %s, login %dms, %d retries\n", elapsed.Round(time.Millisecond), attempt.LatencyMs, attempt.Retries)
+	if attempt.HTTPCode != 0 {
+		fmt.Fprintf(&b, "HTTP %d ", attempt.HTTPCode)
+	}
+	if attempt.ErrorCode != "" {
+		fmt.Fprintf(&b, "%s: ", attempt.ErrorCode)
+	}
+	if attempt.HTTPCode != 0 || attempt.ErrorCode != "" || attempt.Message != "" {
+		fmt.Fprintln(&b, attempt.Message)
+	}
+	if attempt.FailedProbe != "" {
+		fmt.Fprintf(&b, "Failed probe: %s\n", attempt.FailedProbe)
+	}
+	return b.String()
+}
+
+// listCommand prints the registered accounts with their last login status,
+// restricted to one environment with -env.
+func listCommand(args []string) {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	env := flags.String("env", "", "environment of the accounts, all when empty")
+	parseFlags(flags, args)
+
+	withDatabase(func() {
+		accounts, err := action.ListAccounts(*env)
+		if err != nil {
+			exit(exitFailed, "Error when listing accounts: ", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ENVIRONMENT\tUSERNAME\tTYPE\tSTATUS\tLAST STATUS\tLAST ATTEMPT\tFAILURES")
+		for _, account := range accounts {
+			lastAttempt, failures := "-", 0
+			if account.LastAttemptTime != nil {
+				lastAttempt = account.LastAttemptTime.Local().Format("2006-01-02 15:04:05")
+			}
+			if account.ConsecutiveFailures != nil {
+				failures = *account.ConsecutiveFailures
+			}
+			lastStatus := account.LastStatus
+			if lastStatus == "" {
+				lastStatus = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\n", account.DomainType, account.Username,
+				account.Type, account.Status, lastStatus, lastAttempt, failures)
+		}
+		_ = w.Flush()
+		fmt.Printf("%d account(s)\n", len(accounts))
+	})
+}
+
+// validateCommand checks the account seed file, ACCOUNT_SEED_FILE or
+// ./account.json unless given, against the environments, without a database.
+func validateCommand(args []string) {
+	if len(args) > 1 {
+		exit(exitConfig, "Usage: validate [account.json]")
+	}
+	path := accountSeedFile()
+	if len(args) == 1 {
+		path = args[0]
+	}
+	if err := loadRunConfig(); err != nil {
+		exit(exitConfig, "Error when loading configuration: ", err)
+	}
+
+	seed, invalid, err := action.ValidateAccountSeed(path)
+	if err != nil {
+		exit(exitConfig, "Error when reading account seed: ", err)
+	}
+	for _, problem := range invalid {
+		fmt.Println(problem)
+	}
+	if len(invalid) > 0 {
+		exit(exitConfig, fmt.Sprintf("%d problem(s) in %s", len(invalid), path))
+	}
+	fmt.Printf("%d account(s) valid in %s\n", len(seed), path)
+}
+
+// parseFlags parses the flags of a command, exiting with exitConfig on bad
+// ones rather than with the 2 of flag.ExitOnError.
+func parseFlags(flags *flag.FlagSet, args []string) {
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(exitSuccess)
+	}
+	if err != nil {
+		os.Exit(exitConfig)
+	}
+}
+
+// runExitCode maps the outcome of a finished run to the exit code. A run
+// where every account failed is FAILED, not PARTIAL, and exits with
+// exitFailed.
+func runExitCode(run *model.LoginRun) int {
+	switch run.Status {
+	case model.RunStatus.Success:
+		return exitSuccess
+	case model.RunStatus.Partial:
+		return exitPartial
+	default:
+		return exitFailed
+	}
+}
+
+// encryptCommand seals one value read from stdin, e.g. an environment
+// authorization header or a seed account password.
+func encryptCommand() {
+	keyring := secret.Default()
+	if keyring == nil {
+		exit(exitConfig, secret.ErrNoMasterKey)
+	}
+
+	value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && value == "" {
+		exit(exitConfig, "Error when reading value from stdin: ", err)
+	}
+	sealed, err := keyring.Encrypt(strings.TrimRight(value, "\r\n"))
+	if err != nil {
+		exit(exitFailed, "Error when encrypting value: ", err)
+	}
+	fmt.Println(sealed)
+}
+
+// reencryptCommand seals every stored password with the current MASTER_KEY.
+// Keys being rotated out must be listed in MASTER_KEY_PREVIOUS.
+func reencryptCommand() {
+	dbConfig, err := config.LoadDatabase()
+	if err != nil {
+		exit(exitConfig, "Error when loading database configuration: ", err)
+	}
+
+	client := app.SetupDBClient(dbConfig, func(database *mongo.Database) error {
+		model.InitAccount(database)
+		updated, err := action.ReencryptAccounts()
+		if err != nil {
+			exit(exitFailed, fmt.Sprintf("Re-encrypted %d account(s) before failing: %v", updated, err))
+		}
+		fmt.Printf("Re-encrypted %d account(s) with key %s\n", updated, secret.Default().PrimaryKeyID())
+		return nil
+	})
+	if err = client.Connect(); err != nil {
+		exit(exitFailed, "Error when connecting database: ", err)
+	}
+}
+
+// fakeAuthCommand serves a fake authentication endpoint on PORT (default
+// 9000) with the users of a fakeauth JSON config, for local runs with
+// STG_BASE_URL=http://localhost:9000.
+func fakeAuthCommand(args []string) {
+	if len(args) != 1 {
+		exit(exitConfig, "Usage: fake-auth <config.json>")
+	}
+	content, err := os.ReadFile(args[0])
+	if err != nil {
+		exit(exitConfig, "Error when reading fake auth config: ", err)
+	}
+	var cfg fakeauth.Config
+	if err = json.Unmarshal(content, &cfg); err != nil {
+		exit(exitConfig, "Error when parsing fake auth config: ", err)
+	}
+
+	addr := ":" + strconv.Itoa(sdk.ParseInt(os.Getenv("PORT"), 9000))
+	fmt.Printf("Fake auth serving %d user(s) on %s\n", len(cfg.Users), addr)
+	exit(exitFailed, http.ListenAndServe(addr, fakeauth.New(cfg)))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"example.com/micro/action"
+	"example.com/micro/fakeauth"
+	"example.com/micro/model"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunExitCode(t *testing.T) {
+	setupFakeAuth(t, fakeauth.Config{
+		Users: []*fakeauth.User{
+			{Username: "ok.stg", Password: "secret"},
+			{Username: "wrong.stg", Password: "changed"},
+		},
+	},
+		account("stg", "ok.stg", "secret"),
+		account("stg", "wrong.stg", "secret"),
+	)
+
+	for _, tc := range []struct {
+		username string
+		want     int
+	}{
+		{"ok.stg", exitSuccess},
+		{"wrong.stg", exitFailed},
+		{"", exitPartial},
+	} {
+		opt := action.RunOption{Trigger: model.RunTrigger.Job, Username: tc.username}
+		if tc.username != "" {
+			opt.Environment = "stg"
+		}
+		run, err := action.RunAutoLogin(opt)
+		if err != nil {
+			t.Fatalf("RunAutoLogin(%q): %v", tc.username, err)
+		}
+		if got := runExitCode(run); got != tc.want {
+			t.Errorf("exit code of run %s over %q = %d, want %d", run.Status, tc.username, got, tc.want)
+		}
+	}
+}
+
+func TestTryLogin(t *testing.T) {
+	setupFakeAuth(t, fakeauth.Config{
+		Users: []*fakeauth.User{{Username: "wrong.stg", Password: "changed"}},
+	})
+	disabled := account("stg", "wrong.stg", "secret")
+	disabled.Status = model.AccountStatus.Disabled
+
+	attempt := action.TryLogin(disabled)
+	if attempt.Status != model.AttemptStatus.Failed || attempt.HTTPCode != http.StatusUnauthorized {
+		t.Fatalf("attempt %s with HTTP %d, want FAILED with 401", attempt.Status, attempt.HTTPCode)
+	}
+	out := formatAttempt(attempt, 1500*time.Millisecond)
+	if want := "stg/wrong.stg: FAILED (BAD_CREDENTIALS) in 1.5s"; !strings.HasPrefix(out, want) {
+		t.Fatalf("formatAttempt = %q, want it to start with %q", out, want)
+	}
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const defaultAccessFile = "./access.json"
+
+// AccessConfig decides who may call the admin API. Callers present an OIDC ID
+// token issued for Audience by one of Issuers and signed by a key of JWKS, a
+// URL or a local file; Bindings grant roles (viewer, operator, admin) to
+// members such as "user:qa@example.com", "group:qa" or "domain:example.com".
+type AccessConfig struct {
+	Audience string              `json:"audience"`
+	JWKS     string              `json:"jwks,omitempty"`
+	Issuers  []string            `json:"issuers,omitempty"`
+	Bindings map[string][]string `json:"bindings"`
+}
+
+// Access is the admin API access policy; nil when no access file exists.
+var Access *AccessConfig
+
+// LoadAccess reads the access policy from a JSON file.
+// When path is empty it falls back to $ACCESS_FILE, then to ./access.json;
+// a missing default file leaves Access nil.
+func LoadAccess(path string) error {
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv("ACCESS_FILE")
+		explicit = path != ""
+	}
+	if !explicit {
+		path = defaultAccessFile
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			Access = nil
+			return nil
+		}
+		return fmt.Errorf("read access file %s: %w", path, err)
+	}
+
+	var access AccessConfig
+	if err = json.Unmarshal(content, &access); err != nil {
+		return fmt.Errorf("parse access file %s: %w", path, err)
+	}
+	if access.Audience == "" {
+		return fmt.Errorf("access file %s: missing audience", path)
+	}
+	if len(access.Bindings) == 0 {
+		return fmt.Errorf("access file %s: no binding", path)
+	}
+	Access = &access
+	return nil
+}
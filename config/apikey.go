@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"example.com/micro/secret"
+)
+
+// APIKeys are the keys accepted in the X-API-Key header of the token broker,
+// sealed with the secret package. Empty disables the broker.
+var APIKeys []string
+
+// LoadAPIKeys reads the comma separated TOKEN_API_KEYS.
+func LoadAPIKeys() error {
+	var keys []string
+	for i, key := range strings.Split(os.Getenv("TOKEN_API_KEYS"), ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if err := secret.CheckStored(key); err != nil {
+			return fmt.Errorf("TOKEN_API_KEYS #%d: %w", i, err)
+		}
+		keys = append(keys, key)
+	}
+	APIKeys = keys
+	return nil
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"errors"
+	"os"
+
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/db"
+)
+
+// LoadDatabase reads the MongoDB connection settings from the environment.
+func LoadDatabase() (db.Configuration, error) {
+	conf := db.Configuration{
+		Address:  os.Getenv("DB_ADDRESS"),
+		Username: os.Getenv("DB_USERNAME"),
+		Password: os.Getenv("DB_PASSWORD"),
+		AuthDB:   os.Getenv("DB_AUTH_SOURCE"),
+		DBName:   os.Getenv("DB_NAME"),
+	}
+	if conf.AuthDB == "" {
+		conf.AuthDB = "admin"
+	}
+	if conf.DBName == "" {
+		conf.DBName = "autologin"
+	}
+
+	if conf.Address == "" {
+		return conf, errors.New("DB_ADDRESS is required")
+	}
+	// the SDK derives the client name from the password suffix
+	if conf.Username == "" || len(conf.Password) < 5 {
+		return conf, errors.New("DB_USERNAME and DB_PASSWORD (at least 5 characters) are required")
+	}
+	return conf, nil
+}
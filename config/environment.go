@@ -0,0 +1,237 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"example.com/micro/secret"
+)
+
+const (
+	DefaultAuthPath        = "/core/account/v1/authentication"
+	defaultEnvironmentFile = "./environment.json"
+
+	// DefaultRateLimit and DefaultBurst bound the login calls sent to one
+	// environment, well below the auth service's abuse protection.
+	DefaultRateLimit = 2.0
+	DefaultBurst     = 2
+
+	// DefaultTokenField and DefaultTokenExpiryField name the fields of the
+	// first login response item holding the session token and its expiry.
+	DefaultTokenField       = "bearerToken"
+	DefaultTokenExpiryField = "expiredTime"
+
+	// DefaultOTPPath is the second login step of accounts protected by TOTP.
+	// The first step answers with a challenge in DefaultOTPChallengeField,
+	// sent back to the OTP path with the code in DefaultOTPCodeField.
+	DefaultOTPPath           = "/core/account/v1/authentication/otp"
+	DefaultOTPChallengeField = "otpToken"
+	DefaultOTPCodeField      = "otp"
+)
+
+// Environment describes one target the login driver can authenticate against.
+// Authorization is the header sent with the login call, sealed with the
+// secret package; only INSECURE_DEV allows an environment without one.
+// RateLimit is in login calls per second; a negative value
+// disables limiting.
+type Environment struct {
+	Name             string  `json:"name"`
+	Domain           string  `json:"domain"`
+	AuthPath         string  `json:"authPath,omitempty"`
+	Authorization    string  `json:"authorization,omitempty"`
+	RateLimit        float64 `json:"rateLimit,omitempty"`
+	Burst            int     `json:"burst,omitempty"`
+	TokenField       string  `json:"tokenField,omitempty"`
+	TokenExpiryField string  `json:"tokenExpiryField,omitempty"`
+
+	OTPPath           string `json:"otpPath,omitempty"`
+	OTPChallengeField string `json:"otpChallengeField,omitempty"`
+	OTPCodeField      string `json:"otpCodeField,omitempty"`
+}
+
+// Environments is the registry of known environments, keyed by name.
+// It holds the built-in stg/dev targets until LoadEnvironments replaces it.
+var Environments = map[string]*Environment{
+	"stg": {
+		Name:             "stg",
+		Domain:           StgPublicDomain,
+		AuthPath:         DefaultAuthPath,
+		RateLimit:        DefaultRateLimit,
+		Burst:            DefaultBurst,
+		TokenField:       DefaultTokenField,
+		TokenExpiryField: DefaultTokenExpiryField,
+
+		OTPPath:           DefaultOTPPath,
+		OTPChallengeField: DefaultOTPChallengeField,
+		OTPCodeField:      DefaultOTPCodeField,
+	},
+	"dev": {
+		Name:             "dev",
+		Domain:           DevPublicDomain,
+		AuthPath:         DefaultAuthPath,
+		RateLimit:        DefaultRateLimit,
+		Burst:            DefaultBurst,
+		TokenField:       DefaultTokenField,
+		TokenExpiryField: DefaultTokenExpiryField,
+
+		OTPPath:           DefaultOTPPath,
+		OTPChallengeField: DefaultOTPChallengeField,
+		OTPCodeField:      DefaultOTPCodeField,
+	},
+}
+
+// LoadEnvironments reads the environment registry from a JSON file.
+// When path is empty it falls back to $ENVIRONMENT_FILE, then to ./environment.json;
+// a missing default file keeps the built-in registry. <NAME>_BASE_URL
+// overrides the domain of an environment either way. Every environment must
+// have an authorization unless INSECURE_DEV is set.
+func LoadEnvironments(path string) error {
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv("ENVIRONMENT_FILE")
+		explicit = path != ""
+	}
+	if !explicit {
+		path = defaultEnvironmentFile
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			applyBaseURLOverrides(Environments)
+			return requireAuthorization(Environments)
+		}
+		return fmt.Errorf("read environment file %s: %w", path, err)
+	}
+
+	var list []*Environment
+	if err = json.Unmarshal(content, &list); err != nil {
+		return fmt.Errorf("parse environment file %s: %w", path, err)
+	}
+
+	registry, err := buildRegistry(list)
+	if err != nil {
+		return fmt.Errorf("environment file %s: %w", path, err)
+	}
+	if err = requireAuthorization(registry); err != nil {
+		return fmt.Errorf("environment file %s: %w", path, err)
+	}
+	applyBaseURLOverrides(registry)
+	Environments = registry
+	return nil
+}
+
+// requireAuthorization fails on the first environment, by name, without an
+// authorization: the partner auth service refuses logins without it.
+func requireAuthorization(registry map[string]*Environment) error {
+	if secret.AllowPlaintext() {
+		return nil
+	}
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if registry[name].Authorization == "" {
+			return fmt.Errorf("environment %q: missing authorization: seal the header with `server encrypt` and "+
+				"provide it through ENVIRONMENT_FILE (INSECURE_DEV=true skips the check for local development)", name)
+		}
+	}
+	return nil
+}
+
+// BaseURLVariable returns the variable overriding the domain of the named
+// environment, e.g. STG_BASE_URL for "stg".
+func BaseURLVariable(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name) + "_BASE_URL"
+}
+
+// applyBaseURLOverrides points environments at another host, such as a local
+// fake auth server, without editing environment.json.
+func applyBaseURLOverrides(registry map[string]*Environment) {
+	for name, env := range registry {
+		if domain := os.Getenv(BaseURLVariable(name)); domain != "" {
+			env.Domain = domain
+		}
+	}
+}
+
+func buildRegistry(list []*Environment) (map[string]*Environment, error) {
+	if len(list) == 0 {
+		return nil, errors.New("no environment declared")
+	}
+
+	registry := make(map[string]*Environment, len(list))
+	for i, env := range list {
+		if env == nil || env.Name == "" {
+			return nil, fmt.Errorf("environment #%d: missing name", i)
+		}
+		if env.Domain == "" {
+			return nil, fmt.Errorf("environment %q: missing domain", env.Name)
+		}
+		if _, ok := registry[env.Name]; ok {
+			return nil, fmt.Errorf("environment %q: declared more than once", env.Name)
+		}
+		if env.AuthPath == "" {
+			env.AuthPath = DefaultAuthPath
+		}
+		if env.RateLimit == 0 {
+			env.RateLimit = DefaultRateLimit
+		}
+		if env.Burst < 0 {
+			return nil, fmt.Errorf("environment %q: negative burst", env.Name)
+		}
+		if env.Burst == 0 {
+			env.Burst = DefaultBurst
+		}
+		if env.TokenField == "" {
+			env.TokenField = DefaultTokenField
+		}
+		if env.TokenExpiryField == "" {
+			env.TokenExpiryField = DefaultTokenExpiryField
+		}
+		if env.OTPPath == "" {
+			env.OTPPath = DefaultOTPPath
+		}
+		if env.OTPChallengeField == "" {
+			env.OTPChallengeField = DefaultOTPChallengeField
+		}
+		if env.OTPCodeField == "" {
+			env.OTPCodeField = DefaultOTPCodeField
+		}
+		if err := secret.CheckStored(env.Authorization); err != nil {
+			return nil, fmt.Errorf("environment %q: authorization: %w", env.Name, err)
+		}
+		registry[env.Name] = env
+	}
+	return registry, nil
+}
+
+// GetEnvironment returns the registered environment with the given name.
+func GetEnvironment(name string) (*Environment, bool) {
+	env, ok := Environments[name]
+	return env, ok
+}
+
+// EnvironmentNames returns the registered environment names in sorted order.
+func EnvironmentNames() []string {
+	names := make([]string, 0, len(Environments))
+	for name := range Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
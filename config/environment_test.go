@@ -0,0 +1,54 @@
+package config
+
+import (
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+
+	"example.com/micro/secret"
+)
+
+func initSecrets(t *testing.T) {
+	t.Setenv("INSECURE_DEV", "")
+	t.Setenv("MASTER_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err := secret.Init(); err != nil {
+		t.Fatalf("secret.Init: %v", err)
+	}
+}
+
+func TestLoadEnvironmentsRequiresAuthorization(t *testing.T) {
+	initSecrets(t)
+	builtin := Environments
+	t.Cleanup(func() { Environments = builtin })
+
+	// The environment.json shipped in the image has no authorization.
+	err := LoadEnvironments("../environment.json")
+	if err == nil {
+		t.Fatal("LoadEnvironments accepted environments without authorization")
+	}
+	for _, want := range []string{`environment "dev": missing authorization`, "server encrypt", "ENVIRONMENT_FILE"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+	if Environments["stg"] != builtin["stg"] {
+		t.Error("a rejected file replaced the registry")
+	}
+
+	sealed, err := secret.Encrypt("Basic dGVzdDp0ZXN0")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	path := t.TempDir() + "/environment.json"
+	content := `[{"name": "stg", "domain": "https://stg.example.com", "authorization": "` + sealed + `"}]`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadEnvironments(path); err != nil {
+		t.Fatalf("LoadEnvironments with a sealed authorization: %v", err)
+	}
+	if env, ok := GetEnvironment("stg"); !ok || env.Authorization != sealed {
+		t.Fatalf("stg = %+v, want the sealed authorization of the file", env)
+	}
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AutoLoginCron and AutoLoginTimeZone seed the AUTO_LOGIN schedule the first
+// time it is created; afterwards the stored schedule is authoritative.
+// The default fires every day at midnight in Ho Chi Minh City (17:00 UTC).
+var (
+	AutoLoginCron     = envOrDefault("AUTO_LOGIN_CRON", "0 0 * * *")
+	AutoLoginTimeZone = envOrDefault("AUTO_LOGIN_TIMEZONE", "Asia/Ho_Chi_Minh")
+)
+
+// JourneyDir holds the YAML synthetic journeys, read from JOURNEY_DIR.
+var JourneyDir = envOrDefault("JOURNEY_DIR", "./journeys")
+
+// LoginConcurrency is the number of accounts logged in at the same time
+// during a run, and LoginRetries how many times a failed login call is
+// retried when the failure is transient.
+var (
+	LoginConcurrency = envIntOrDefault("LOGIN_CONCURRENCY", 4, 1)
+	LoginRetries     = envIntOrDefault("LOGIN_RETRIES", 2, 0)
+)
+
+// RunLockTTL is how long the instance executing a login run holds the run
+// lock without a heartbeat, read from RUN_LOCK_TTL. Past it, another instance
+// takes the run over.
+var RunLockTTL = envDurationOrDefault("RUN_LOCK_TTL", 2*time.Minute)
+
+// PauseThreshold is the number of consecutive credential failures (wrong
+// password, locked account) after which an account is paused, read from
+// PAUSE_THRESHOLD. 0 never pauses.
+var PauseThreshold = envIntOrDefault("PAUSE_THRESHOLD", 3, 0)
+
+// LeaseTTL is how long an account lease lasts when the request does not
+// say, read from LEASE_TTL; LeaseMaxTTL caps any lease or renewal, read from
+// LEASE_MAX_TTL.
+var (
+	LeaseTTL    = envDurationOrDefault("LEASE_TTL", 30*time.Minute)
+	LeaseMaxTTL = envDurationOrDefault("LEASE_MAX_TTL", 8*time.Hour)
+)
+
+// TokenTTL is how long a captured session token is considered valid when
+// neither the token nor the login response says, read from TOKEN_TTL
+// (e.g. "12h"). TokenRefreshMargin is how long before expiry an account is
+// logged in again, read from TOKEN_REFRESH_MARGIN.
+var (
+	TokenTTL           = envDurationOrDefault("TOKEN_TTL", 24*time.Hour)
+	TokenRefreshMargin = envDurationOrDefault("TOKEN_REFRESH_MARGIN", time.Hour)
+)
+
+// AccountTypes are the account types the auth service knows, read from
+// ACCOUNT_TYPES as a comma separated list. Accounts of another type are
+// rejected before they reach a login.
+var AccountTypes = envListOrDefault("ACCOUNT_TYPES", []string{"EMPLOYEE", "CUSTOMER", "SELLER", "SUPPLIER"})
+
+// IsAccountType reports whether accountType is one of AccountTypes.
+func IsAccountType(accountType string) bool {
+	for _, t := range AccountTypes {
+		if t == accountType {
+			return true
+		}
+	}
+	return false
+}
+
+// AccountFile, read from ACCOUNT_FILE, defines the accounts of the registry
+// instead of the accounts API, e.g. a mounted ConfigMap. It is checked for
+// changes every AccountReloadInterval, read from ACCOUNT_RELOAD_INTERVAL.
+var (
+	AccountFile           = os.Getenv("ACCOUNT_FILE")
+	AccountReloadInterval = envDurationOrDefault("ACCOUNT_RELOAD_INTERVAL", 30*time.Second)
+)
+
+// invalidSettings are the variables above whose value could not be used.
+// They keep their default until CheckSettings reports them.
+var invalidSettings []string
+
+// CheckSettings reports the variables above set to a value that is not a
+// valid number or duration, or is out of range.
+func CheckSettings() error {
+	if len(invalidSettings) > 0 {
+		return fmt.Errorf("invalid settings: %s", strings.Join(invalidSettings, "; "))
+	}
+	return nil
+}
+
+func envOrDefault(name, value string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return value
+}
+
+func envIntOrDefault(name string, value, min int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return value
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < min {
+		invalidSettings = append(invalidSettings, fmt.Sprintf("%s=%q is not an integer of at least %d", name, raw, min))
+		return value
+	}
+	return v
+}
+
+func envListOrDefault(name string, value []string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	if len(list) == 0 {
+		return value
+	}
+	return list
+}
+
+func envDurationOrDefault(name string, value time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return value
+	}
+	v, err := time.ParseDuration(raw)
+	if err != nil || v <= 0 {
+		invalidSettings = append(invalidSettings, fmt.Sprintf("%s=%q is not a positive duration such as 30s or 2m", name, raw))
+		return value
+	}
+	return v
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"example.com/micro/secret"
+)
+
+const (
+	WebhookSlack      = "slack"
+	WebhookGoogleChat = "googlechat"
+	WebhookJSON       = "json"
+
+	defaultWebhookFile = "./webhook.json"
+)
+
+// Webhook is a chat or HTTP endpoint notified about login state changes.
+// URL usually embeds a token, so it is sealed with the secret package.
+type Webhook struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	URL  string `json:"url"`
+}
+
+// Webhooks lists the notification targets; empty disables notifications.
+var Webhooks []*Webhook
+
+// LoadWebhooks reads the notification targets from a JSON file.
+// When path is empty it falls back to $WEBHOOK_FILE, then to ./webhook.json;
+// a missing default file leaves notifications disabled.
+func LoadWebhooks(path string) error {
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv("WEBHOOK_FILE")
+		explicit = path != ""
+	}
+	if !explicit {
+		path = defaultWebhookFile
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("read webhook file %s: %w", path, err)
+	}
+
+	var list []*Webhook
+	if err = json.Unmarshal(content, &list); err != nil {
+		return fmt.Errorf("parse webhook file %s: %w", path, err)
+	}
+	for i, hook := range list {
+		if hook == nil || hook.URL == "" {
+			return fmt.Errorf("webhook file %s: webhook #%d: missing url", path, i)
+		}
+		switch hook.Kind {
+		case WebhookSlack, WebhookGoogleChat, WebhookJSON:
+		case "":
+			hook.Kind = WebhookJSON
+		default:
+			return fmt.Errorf("webhook file %s: webhook #%d: unknown kind %q", path, i, hook.Kind)
+		}
+		if err = secret.CheckStored(hook.URL); err != nil {
+			return fmt.Errorf("webhook file %s: webhook #%d: url: %w", path, i, err)
+		}
+	}
+	Webhooks = list
+	return nil
+}
@@ -0,0 +1,61 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	hcm, err := time.LoadLocation("Asia/Ho_Chi_Minh")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+
+	tests := []struct {
+		expr, zone string
+		from, want time.Time
+	}{
+		{"0 0 * * *", "Asia/Ho_Chi_Minh",
+			time.Date(2026, 10, 18, 16, 59, 0, 0, time.UTC), time.Date(2026, 10, 19, 0, 0, 0, 0, hcm)},
+		{"0 0 * * *", "Asia/Ho_Chi_Minh",
+			time.Date(2026, 10, 18, 17, 0, 0, 0, time.UTC), time.Date(2026, 10, 20, 0, 0, 0, 0, hcm)},
+		{"*/15 9-17 * * mon-fri", "",
+			time.Date(2026, 10, 16, 17, 50, 0, 0, time.UTC), time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)},
+		{"30 8 1 * 0", "",
+			time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 4, 8, 30, 0, 0, time.UTC)},
+		{"0 12 29 2 *", "",
+			time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC)},
+		{"@hourly", "",
+			time.Date(2026, 10, 18, 5, 0, 0, 0, time.UTC), time.Date(2026, 10, 18, 6, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr, tt.zone)
+		if err != nil {
+			t.Fatalf("Parse(%q, %q): %v", tt.expr, tt.zone, err)
+		}
+		if got := s.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("Parse(%q, %q).Next(%v) = %v, want %v", tt.expr, tt.zone, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestParseRejectsInvalidExpressions(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "5-1 * * * *", "*/0 * * * *", "0 0 * foo *"} {
+		if _, err := Parse(expr, ""); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", expr)
+		}
+	}
+	if _, err := Parse("0 0 * * *", "Mars/Olympus"); err == nil {
+		t.Error("Parse with an unknown time zone succeeded, want an error")
+	}
+}
+
+func TestNextNeverMatching(t *testing.T) {
+	s, err := Parse("0 0 30 2 *", "")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := s.Next(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Errorf("Next = %v, want the zero time", got)
+	}
+}
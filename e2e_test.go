@@ -0,0 +1,162 @@
+package main
+
+import (
+	"example.com/micro/action"
+	"example.com/micro/client/login"
+	"example.com/micro/config"
+	"example.com/micro/fakeauth"
+	"example.com/micro/metrics"
+	"example.com/micro/model"
+	"example.com/micro/secret"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type staticSource []*model.Account
+
+func (s staticSource) ActiveAccounts(domainType string) ([]*model.Account, error) {
+	var accounts []*model.Account
+	for _, account := range s {
+		if account.Status == model.AccountStatus.Active && (domainType == "" || account.DomainType == domainType) {
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts, nil
+}
+
+// setupFakeAuth points stg and dev from environment.json at a fake auth
+// server through their base-URL overrides, and logs in accounts instead of
+// reading them from Mongo.
+func setupFakeAuth(t *testing.T, cfg fakeauth.Config, accounts ...*model.Account) *fakeauth.Server {
+	t.Helper()
+	t.Setenv("INSECURE_DEV", "true")
+	if err := secret.Init(); err != nil {
+		t.Fatalf("secret.Init: %v", err)
+	}
+
+	fake := fakeauth.New(cfg)
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	t.Setenv(config.BaseURLVariable("stg"), srv.URL)
+	t.Setenv(config.BaseURLVariable("dev"), srv.URL)
+
+	if err := config.LoadEnvironments("environment.json"); err != nil {
+		t.Fatalf("LoadEnvironments: %v", err)
+	}
+	for _, env := range config.Environments {
+		env.RateLimit = -1
+	}
+	login.InitLoginClients()
+	login.Retry.BaseDelay = time.Millisecond
+	login.Retry.MaxDelay = 10 * time.Millisecond
+
+	action.SetAccountSource(staticSource(accounts))
+	t.Cleanup(func() { action.SetAccountSource(action.RegistrySource{}) })
+	action.SetLeaderLock(&memoryLock{})
+	t.Cleanup(func() { action.SetLeaderLock(action.MongoLeaderLock{}) })
+	return fake
+}
+
+// memoryLock is a run lock shared by the instances of a single process.
+type memoryLock struct {
+	mu    sync.Mutex
+	token int64
+	runID string
+}
+
+func (l *memoryLock) Acquire(runID string) (*action.LockGrant, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.runID != "" {
+		return nil, action.ErrRunInProgress
+	}
+	l.token++
+	l.runID = runID
+	return &action.LockGrant{Holder: "test", Token: l.token, RunID: runID}, nil
+}
+
+func (l *memoryLock) Takeover() (*action.LockGrant, error) {
+	return nil, nil
+}
+
+func (l *memoryLock) Renew(grant *action.LockGrant) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if grant.Token != l.token {
+		return action.ErrLockLost
+	}
+	return nil
+}
+
+func (l *memoryLock) Release(grant *action.LockGrant) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if grant.Token != l.token {
+		return action.ErrLockLost
+	}
+	l.runID = ""
+	return nil
+}
+
+func account(domainType, username, password string) *model.Account {
+	return &model.Account{
+		Username:   username,
+		Password:   password,
+		Type:       "EMPLOYEE",
+		DomainType: domainType,
+		Status:     model.AccountStatus.Active,
+	}
+}
+
+func TestAutoLoginAgainstFakeAuth(t *testing.T) {
+	fake := setupFakeAuth(t, fakeauth.Config{
+		Users: []*fakeauth.User{
+			{Username: "ok.stg", Password: "secret", LatencyMs: 20},
+			{Username: "ok.dev", Password: "secret"},
+			{Username: "wrong.stg", Password: "changed"},
+			{Username: "flaky.stg", Password: "secret", FailCode: http.StatusBadGateway, FailTimes: 2},
+		},
+	},
+		account("stg", "ok.stg", "secret"),
+		account("dev", "ok.dev", "secret"),
+		account("stg", "wrong.stg", "secret"),
+		account("stg", "flaky.stg", "secret"),
+		account("prd", "unknown.prd", "secret"),
+	)
+
+	run, err := action.RunAutoLogin(action.RunOption{Trigger: model.RunTrigger.API})
+	if err != nil {
+		t.Fatalf("RunAutoLogin: %v", err)
+	}
+	if run.Status != model.RunStatus.Partial || *run.Total != 5 || *run.Success != 3 || *run.Fail != 1 || *run.ConfigError != 1 {
+		t.Fatalf("run %s: total %d, success %d, fail %d, config error %d; want PARTIAL_FAILURE 5/3/1/1",
+			run.Status, *run.Total, *run.Success, *run.Fail, *run.ConfigError)
+	}
+	if got := fake.Calls("wrong.stg"); got != 1 {
+		t.Errorf("wrong password called %d times, want 1 (401 is never retried)", got)
+	}
+	if got := fake.Calls("flaky.stg"); got != 3 {
+		t.Errorf("flaky account called %d times, want 3 (two 502 then success)", got)
+	}
+	if got := fake.Calls("unknown.prd"); got != 0 {
+		t.Errorf("account of unknown environment called %d times, want 0", got)
+	}
+
+	var exposition strings.Builder
+	if err = metrics.Default.WriteText(&exposition); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	for _, series := range []string{
+		`autologin_login_attempts_total{environment="stg",type="EMPLOYEE",class="BAD_CREDENTIALS"}`,
+		`autologin_login_attempts_total{environment="prd",type="EMPLOYEE",class="CONFIG_ERROR"}`,
+		`autologin_login_duration_seconds_bucket{environment="dev",le="+Inf"}`,
+	} {
+		if !strings.Contains(exposition.String(), series) {
+			t.Errorf("metrics lack %s", series)
+		}
+	}
+}
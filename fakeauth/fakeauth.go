@@ -0,0 +1,262 @@
+// Package fakeauth is an in-process stand-in for the thuocsi
+// /core/account/v1/authentication endpoint and its TOTP second step, for
+// tests and local runs that must not reach the real auth service.
+package fakeauth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"example.com/micro/totp"
+	"github.com/dgrijalva/jwt-go"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultPath    = "/core/account/v1/authentication"
+	DefaultOTPPath = "/core/account/v1/authentication/otp"
+)
+
+// User is an account known to the fake, answering after LatencyMs. FailCode and ErrorCode force a
+// failure response; FailTimes limits it to the first calls, 0 meaning every
+// call fails. A user with a base32 TOTPSeed gets an otpToken challenge
+// instead of a session, to be sent to the OTP path with the current code as
+// otp.
+type User struct {
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	TOTPSeed  string `json:"totpSeed,omitempty"`
+	LatencyMs int    `json:"latencyMs,omitempty"`
+	FailCode  int    `json:"failCode,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
+	FailTimes int    `json:"failTimes,omitempty"`
+}
+
+// Config describes the fake. After LockoutThreshold consecutive wrong
+// passwords or codes a user is locked and every later call is refused; 0 disables
+// lockout. When Authorization is set, calls must send it as header. Issued
+// tokens are JWTs expiring after TokenTTLSeconds (default one hour).
+type Config struct {
+	Path             string  `json:"path,omitempty"`
+	OTPPath          string  `json:"otpPath,omitempty"`
+	Authorization    string  `json:"authorization,omitempty"`
+	LockoutThreshold int     `json:"lockoutThreshold,omitempty"`
+	TokenTTLSeconds  int     `json:"tokenTtlSeconds,omitempty"`
+	Users            []*User `json:"users"`
+}
+
+// Server is an http.Handler serving the fake authentication endpoint.
+type Server struct {
+	cfg Config
+
+	mu       sync.Mutex
+	users    map[string]*User
+	calls    map[string]int
+	failures map[string]int
+	locked   map[string]bool
+	pending  map[string]string // OTP challenge to username
+}
+
+type response struct {
+	Status    string        `json:"status"`
+	Message   string        `json:"message,omitempty"`
+	ErrorCode string        `json:"errorCode,omitempty"`
+	Data      []interface{} `json:"data,omitempty"`
+}
+
+// New returns a fake serving cfg.
+func New(cfg Config) *Server {
+	if cfg.Path == "" {
+		cfg.Path = DefaultPath
+	}
+	if cfg.OTPPath == "" {
+		cfg.OTPPath = DefaultOTPPath
+	}
+	if cfg.TokenTTLSeconds <= 0 {
+		cfg.TokenTTLSeconds = 3600
+	}
+	s := &Server{
+		cfg:      cfg,
+		users:    make(map[string]*User, len(cfg.Users)),
+		calls:    make(map[string]int),
+		failures: make(map[string]int),
+		locked:   make(map[string]bool),
+		pending:  make(map[string]string),
+	}
+	for _, u := range cfg.Users {
+		s.users[u.Username] = u
+	}
+	return s
+}
+
+// Calls returns how many login calls, OTP steps included, the fake received
+// for username.
+func (s *Server) Calls(username string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[username]
+}
+
+// Locked reports whether username hit the lockout threshold.
+func (s *Server) Locked(username string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.locked[username]
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if (r.URL.Path != s.cfg.Path && r.URL.Path != s.cfg.OTPPath) || r.Method != http.MethodPost {
+		reply(w, http.StatusNotFound, response{Status: "NOT_FOUND", Message: "Not found", ErrorCode: "NOT_FOUND"})
+		return
+	}
+	if s.cfg.Authorization != "" && r.Header.Get("Authorization") != s.cfg.Authorization {
+		reply(w, http.StatusUnauthorized, response{Status: "UNAUTHORIZED", Message: "Invalid partner authorization", ErrorCode: "INVALID_AUTHORIZATION"})
+		return
+	}
+
+	var input struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		OTPToken string `json:"otpToken"`
+		OTP      string `json:"otp"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		reply(w, http.StatusBadRequest, response{Status: "INVALID", Message: err.Error(), ErrorCode: "INVALID_BODY"})
+		return
+	}
+
+	var code int
+	var resp response
+	var latency time.Duration
+	if r.URL.Path == s.cfg.OTPPath {
+		code, resp, latency = s.verifyOTP(input.OTPToken, input.OTP)
+	} else {
+		code, resp, latency = s.login(input.Username, input.Password)
+	}
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	reply(w, code, resp)
+}
+
+func (s *Server) login(username, password string) (int, response, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls[username]++
+	u, ok := s.users[username]
+	if !ok {
+		return http.StatusUnauthorized, response{Status: "UNAUTHORIZED", Message: "Account not found", ErrorCode: "ACCOUNT_NOT_FOUND"}, 0
+	}
+	latency := time.Duration(u.LatencyMs) * time.Millisecond
+	if s.locked[username] {
+		return http.StatusForbidden, response{Status: "FORBIDDEN", Message: "Account is locked", ErrorCode: "ACCOUNT_LOCKED"}, latency
+	}
+	if u.FailCode != 0 && (u.FailTimes == 0 || s.calls[username] <= u.FailTimes) {
+		return u.FailCode, response{Status: statusText(u.FailCode), Message: "Forced failure", ErrorCode: u.ErrorCode}, latency
+	}
+	if password != u.Password {
+		s.fail(username)
+		return http.StatusUnauthorized, response{Status: "UNAUTHORIZED", Message: "Wrong password", ErrorCode: "WRONG_PASSWORD"}, latency
+	}
+
+	if u.TOTPSeed != "" {
+		challenge := make([]byte, 16)
+		rand.Read(challenge)
+		s.pending[hex.EncodeToString(challenge)] = username
+		return http.StatusOK, response{
+			Status:  "OK",
+			Message: "OTP required",
+			Data: []interface{}{map[string]interface{}{
+				"username": username,
+				"otpToken": hex.EncodeToString(challenge),
+			}},
+		}, latency
+	}
+	return http.StatusOK, s.success(username), latency
+}
+
+// verifyOTP accepts the code of the current or the previous time step, once
+// per challenge.
+func (s *Server) verifyOTP(challenge, code string) (int, response, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	username, ok := s.pending[challenge]
+	if !ok {
+		return http.StatusUnauthorized, response{Status: "UNAUTHORIZED", Message: "Unknown or used OTP token", ErrorCode: "INVALID_OTP_TOKEN"}, 0
+	}
+	delete(s.pending, challenge)
+	s.calls[username]++
+	u := s.users[username]
+	latency := time.Duration(u.LatencyMs) * time.Millisecond
+	if s.locked[username] {
+		return http.StatusForbidden, response{Status: "FORBIDDEN", Message: "Account is locked", ErrorCode: "ACCOUNT_LOCKED"}, latency
+	}
+
+	now := time.Now()
+	current, _ := totp.Code(u.TOTPSeed, now)
+	previous, _ := totp.Code(u.TOTPSeed, now.Add(-totp.Period))
+	if code == "" || (code != current && code != previous) {
+		s.fail(username)
+		return http.StatusUnauthorized, response{Status: "UNAUTHORIZED", Message: "Wrong OTP", ErrorCode: "WRONG_OTP"}, latency
+	}
+	return http.StatusOK, s.success(username), latency
+}
+
+func (s *Server) fail(username string) {
+	s.failures[username]++
+	if s.cfg.LockoutThreshold > 0 && s.failures[username] >= s.cfg.LockoutThreshold {
+		s.locked[username] = true
+	}
+}
+
+func (s *Server) success(username string) response {
+	s.failures[username] = 0
+	return response{
+		Status:  "OK",
+		Message: "Login successfully",
+		Data: []interface{}{map[string]interface{}{
+			"username":    username,
+			"bearerToken": s.newToken(username),
+		}},
+	}
+}
+
+func statusText(code int) string {
+	switch {
+	case code == http.StatusUnauthorized:
+		return "UNAUTHORIZED"
+	case code == http.StatusForbidden:
+		return "FORBIDDEN"
+	case code == http.StatusNotFound:
+		return "NOT_FOUND"
+	case code >= 500:
+		return "ERROR"
+	}
+	return "INVALID"
+}
+
+// newToken issues a JWT signed with a random key: clients can read its
+// claims but nothing can verify it.
+func (s *Server) newToken(username string) string {
+	key := make([]byte, 32)
+	rand.Read(key)
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": username,
+		"exp": time.Now().Add(time.Duration(s.cfg.TokenTTLSeconds) * time.Second).Unix(),
+	}).SignedString(key)
+	return token
+}
+
+func reply(w http.ResponseWriter, code int, resp response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
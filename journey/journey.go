@@ -0,0 +1,364 @@
+// Package journey runs synthetic user journeys, short sequences of API calls
+// described in YAML, against an environment.
+package journey
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"example.com/micro/client"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Journey is one scenario. Steps run in order and share variables: Vars
+// seeds them, Login adds "token" from a fresh session of a registered
+// account, and each step may extract more from its response. Cron and
+// TimeZone schedule the journey; without Cron it only runs on demand.
+type Journey struct {
+	Name        string                 `json:"name" yaml:"name"`
+	Environment string                 `json:"environment" yaml:"environment"`
+	Cron        string                 `json:"cron,omitempty" yaml:"cron,omitempty"`
+	TimeZone    string                 `json:"timeZone,omitempty" yaml:"timeZone,omitempty"`
+	Login       *Login                 `json:"login,omitempty" yaml:"login,omitempty"`
+	Vars        map[string]interface{} `json:"vars,omitempty" yaml:"vars,omitempty"`
+	Steps       []*Step                `json:"steps" yaml:"steps"`
+}
+
+// Login names the registered account whose session the journey uses. Its
+// token is sent as bearer on every step that sets no Authorization header.
+type Login struct {
+	Username string `json:"username" yaml:"username"`
+}
+
+// Step is one API call with client.APIOption semantics: API is
+// "<METHOD>::<path>", ":var_<name>" in the path is replaced from Vars, and
+// Params, Headers and Body are sent as is. Every string may reference a
+// variable as {{name}}; a string that is only "{{name}}" takes the variable
+// with its JSON type. Extract maps variable names to dotted JSON paths of the
+// response.
+type Step struct {
+	Name    string                 `json:"name" yaml:"name"`
+	API     string                 `json:"api" yaml:"api"`
+	Vars    map[string]string      `json:"vars,omitempty" yaml:"vars,omitempty"`
+	Params  map[string]string      `json:"params,omitempty" yaml:"params,omitempty"`
+	Headers map[string]string      `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body    interface{}            `json:"body,omitempty" yaml:"body,omitempty"`
+	Expect  *Expect                `json:"expect,omitempty" yaml:"expect,omitempty"`
+	Extract map[string]string      `json:"extract,omitempty" yaml:"extract,omitempty"`
+	Assert  map[string]interface{} `json:"assert,omitempty" yaml:"assert,omitempty"`
+}
+
+// Expect is the HTTP code and response status a step must get, 200 and "OK"
+// by default.
+type Expect struct {
+	Code   int    `json:"code,omitempty" yaml:"code,omitempty"`
+	Status string `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+const (
+	StepPassed  = "PASSED"
+	StepFailed  = "FAILED"
+	StepSkipped = "SKIPPED"
+)
+
+// StepResult is the outcome of one step.
+type StepResult struct {
+	Name      string `json:"name" bson:"name,omitempty"`
+	Status    string `json:"status" bson:"status,omitempty"`
+	HTTPCode  int    `json:"httpCode,omitempty" bson:"http_code,omitempty"`
+	LatencyMs int64  `json:"latencyMs" bson:"latency_ms"`
+	Message   string `json:"message,omitempty" bson:"message,omitempty"`
+}
+
+// Parse decodes and checks a journey written in YAML. Unknown fields are
+// rejected.
+func Parse(src []byte) (*Journey, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(src))
+	decoder.KnownFields(true)
+	var j Journey
+	if err := decoder.Decode(&j); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("empty journey")
+		}
+		return nil, err
+	}
+	if err := j.validate(); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (j *Journey) validate() error {
+	if j.Name == "" {
+		return errors.New("missing name")
+	}
+	if strings.ContainsAny(j.Name, ": ") {
+		return fmt.Errorf("name %q must not contain spaces or colons", j.Name)
+	}
+	if j.Environment == "" {
+		return errors.New("missing environment")
+	}
+	if j.Login != nil && j.Login.Username == "" {
+		return errors.New("login: missing username")
+	}
+	if len(j.Steps) == 0 {
+		return errors.New("no step")
+	}
+	for i, step := range j.Steps {
+		if step == nil || !strings.Contains(step.API, "::/") {
+			return fmt.Errorf("step #%d: api must look like GET::/path", i)
+		}
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("#%d %s", i, step.API)
+		}
+	}
+	return nil
+}
+
+// LoadDir parses every .yaml and .yml file of dir, sorted by name. A missing
+// directory holds no journey.
+func LoadDir(dir string) ([]*Journey, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var list []*Journey
+	names := make(map[string]string)
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		j, err := Parse(content)
+		if err != nil {
+			return nil, fmt.Errorf("journey %s: %w", path, err)
+		}
+		if other, ok := names[j.Name]; ok {
+			return nil, fmt.Errorf("journey %s: name %q already used by %s", path, j.Name, other)
+		}
+		names[j.Name] = path
+		list = append(list, j)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].Name < list[b].Name })
+	return list, nil
+}
+
+// Run executes the steps against cl with the given initial variables. It
+// stops at the first failing step and reports the rest as skipped.
+func (j *Journey) Run(cl *client.Client, vars map[string]interface{}) []*StepResult {
+	scope := make(map[string]interface{}, len(j.Vars)+len(vars))
+	for k, v := range j.Vars {
+		scope[k] = v
+	}
+	for k, v := range vars {
+		scope[k] = v
+	}
+
+	results := make([]*StepResult, 0, len(j.Steps))
+	failed := false
+	for _, step := range j.Steps {
+		if failed {
+			results = append(results, &StepResult{Name: step.Name, Status: StepSkipped})
+			continue
+		}
+		result := step.run(cl, scope)
+		failed = result.Status != StepPassed
+		results = append(results, result)
+	}
+	return results
+}
+
+func (s *Step) run(cl *client.Client, scope map[string]interface{}) *StepResult {
+	result := &StepResult{Name: s.Name, Status: StepFailed}
+
+	o, err := s.option(scope)
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+	api, err := render(s.API, scope)
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	resp, err := cl.Do(fmt.Sprint(api), cl.WithAPIOption(o))
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+	result.HTTPCode = resp.Code
+
+	if err = s.check(resp, scope); err != nil {
+		result.Message = err.Error()
+		return result
+	}
+	result.Status = StepPassed
+	return result
+}
+
+func (s *Step) option(scope map[string]interface{}) (client.APIOption, error) {
+	o := client.APIOption{
+		Vars:    make(map[string]string, len(s.Vars)),
+		Params:  make(map[string]string, len(s.Params)),
+		Headers: map[string]string{"Content-Type": "application/json"},
+	}
+	if token, ok := scope["token"].(string); ok && token != "" {
+		o.Headers["Authorization"] = "Bearer " + token
+	}
+
+	for _, group := range []struct {
+		from map[string]string
+		to   map[string]string
+	}{{s.Vars, o.Vars}, {s.Params, o.Params}, {s.Headers, o.Headers}} {
+		for k, v := range group.from {
+			rendered, err := render(v, scope)
+			if err != nil {
+				return o, err
+			}
+			group.to[k] = fmt.Sprint(rendered)
+		}
+	}
+
+	if s.Body != nil {
+		body, err := render(s.Body, scope)
+		if err != nil {
+			return o, err
+		}
+		o.Body = body
+	}
+	return o, nil
+}
+
+func (s *Step) check(resp *client.Response, scope map[string]interface{}) error {
+	expect := s.Expect
+	if expect == nil {
+		expect = &Expect{}
+	}
+	code := expect.Code
+	if code == 0 {
+		code = http.StatusOK
+	}
+	if resp.Code != code {
+		return fmt.Errorf("HTTP %d, want %d", resp.Code, code)
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(resp.Body, &doc); err != nil {
+		if expect.Status == "" && len(s.Extract) == 0 && len(s.Assert) == 0 {
+			return nil
+		}
+		return fmt.Errorf("response is not JSON: %v", err)
+	}
+
+	status := expect.Status
+	if status == "" {
+		status = "OK"
+	}
+	if actual, ok := client.Lookup(doc, "status"); ok && actual != status {
+		return fmt.Errorf("status %v, want %s", actual, status)
+	}
+
+	for _, path := range sortedKeys(s.Assert) {
+		want, err := render(s.Assert[path], scope)
+		if err != nil {
+			return err
+		}
+		actual, ok := client.Lookup(doc, path)
+		if !ok {
+			return fmt.Errorf("%s is missing", path)
+		}
+		if !client.SameJSON(actual, want) {
+			return fmt.Errorf("%s is %v, want %v", path, actual, want)
+		}
+	}
+
+	for _, name := range sortedKeys(s.Extract) {
+		value, ok := client.Lookup(doc, s.Extract[name])
+		if !ok {
+			return fmt.Errorf("cannot extract %s: %s is missing", name, s.Extract[name])
+		}
+		scope[name] = value
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var placeholder = regexp.MustCompile(`{{\s*([A-Za-z0-9_.-]+)\s*}}`)
+
+// render replaces {{name}} placeholders in every string of v.
+func render(v interface{}, scope map[string]interface{}) (interface{}, error) {
+	switch value := v.(type) {
+	case string:
+		if m := placeholder.FindStringSubmatch(value); m != nil && m[0] == value {
+			resolved, ok := scope[m[1]]
+			if !ok {
+				return nil, fmt.Errorf("unknown variable %q", m[1])
+			}
+			return resolved, nil
+		}
+		var missing string
+		rendered := placeholder.ReplaceAllStringFunc(value, func(s string) string {
+			name := placeholder.FindStringSubmatch(s)[1]
+			resolved, ok := scope[name]
+			if !ok {
+				missing = name
+				return s
+			}
+			return fmt.Sprint(resolved)
+		})
+		if missing != "" {
+			return nil, fmt.Errorf("unknown variable %q", missing)
+		}
+		return rendered, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			rendered, err := render(item, scope)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = rendered
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, item := range value {
+			rendered, err := render(item, scope)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,128 @@
+package journey
+
+import (
+	"encoding/json"
+	"example.com/micro/client"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const checkout = `
+name: checkout
+environment: stg
+vars:
+  keyword: paracetamol
+steps:
+  - name: search
+    api: GET::/product/search
+    params:
+      q: "{{keyword}}"
+    extract:
+      productId: data.0.productId
+  - name: add to cart
+    api: POST::/cart/:var_cartId/item
+    vars:
+      cartId: "42"
+    body:
+      productId: "{{productId}}"
+      quantity: 2
+    assert:
+      data.0.quantity: 2
+  - name: view cart
+    api: GET::/cart/42
+    expect:
+      code: 200
+`
+
+func TestJourneyRun(t *testing.T) {
+	var cartBody map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /product/search":
+			if r.URL.Query().Get("q") != "paracetamol" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"status":"OK","data":[{"productId":101}]}`))
+		case "POST /cart/42/item":
+			json.NewDecoder(r.Body).Decode(&cartBody)
+			w.Write([]byte(`{"status":"OK","data":[{"quantity":2}]}`))
+		default:
+			w.Write([]byte(`{"status":"NOT_FOUND"}`))
+		}
+	}))
+	defer srv.Close()
+
+	j, err := Parse([]byte(checkout))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	results := j.Run(client.NewClient(srv.URL, -1), map[string]interface{}{"token": "tok"})
+
+	want := []string{StepPassed, StepPassed, StepFailed}
+	for i, result := range results {
+		if result.Status != want[i] {
+			t.Fatalf("step %s: %s (%s), want %s", result.Name, result.Status, result.Message, want[i])
+		}
+	}
+	if cartBody["productId"] != float64(101) || cartBody["quantity"] != float64(2) {
+		t.Fatalf("cart body = %v, want the extracted productId as a number", cartBody)
+	}
+	if results[2].Message != "status NOT_FOUND, want OK" {
+		t.Fatalf("last step message = %q", results[2].Message)
+	}
+}
+
+func TestJourneyStopsAtFirstFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	j, err := Parse([]byte(checkout))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	results := j.Run(client.NewClient(srv.URL, -1), nil)
+	if results[0].Status != StepFailed || results[1].Status != StepSkipped || results[2].Status != StepSkipped {
+		t.Fatalf("statuses %s/%s/%s, want FAILED/SKIPPED/SKIPPED", results[0].Status, results[1].Status, results[2].Status)
+	}
+}
+
+func TestParseRejectsUnknownFields(t *testing.T) {
+	if _, err := Parse([]byte("name: x\nenvironment: stg\nsteps:\n  - api: GET::/a\n    asert: {}\n")); err == nil {
+		t.Fatal("Parse accepted a misspelled field")
+	}
+	if _, err := Parse([]byte("name: x\nname: y\nenvironment: stg\nsteps:\n  - api: GET::/a\n")); err == nil {
+		t.Fatal("Parse accepted a repeated key")
+	}
+	if _, err := Parse(nil); err == nil {
+		t.Fatal("Parse accepted an empty file")
+	}
+}
+
+func TestParseBlockScalar(t *testing.T) {
+	j, err := Parse([]byte(`
+name: notes
+environment: stg
+steps:
+  - api: POST::/note
+    body:
+      text: |
+        first line
+        second line
+      limit: 10
+`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	body, _ := json.Marshal(j.Steps[0].Body)
+	if string(body) != `{"limit":10,"text":"first line\nsecond line\n"}` {
+		t.Fatalf("body %s, want the block scalar and the number", body)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"example.com/micro/action"
+	"fmt"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk"
+	"os"
+	"time"
+)
+
+// shutdown waits for the in-flight login run, then stops the API server.
+// SHUTDOWN_TIMEOUT (seconds, default 10 as Cloud Run) bounds the whole wait.
+func shutdown(server sdk.APIServer) {
+	timeout := time.Duration(sdk.ParseInt(os.Getenv("SHUTDOWN_TIMEOUT"), 10)) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	fmt.Println("Shutting down ...")
+	if err := action.Shutdown(ctx); err != nil {
+		fmt.Println("In-flight login run did not finish in time: ", err)
+	}
+	if s, ok := server.(*sdk.HTTPAPIServer); ok {
+		if err := s.Echo.Shutdown(ctx); err != nil {
+			fmt.Println("Error when stopping API server: ", err)
+		}
+	}
+	fmt.Println("Shutdown complete.")
+}
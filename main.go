@@ -1,34 +1,155 @@
 package main
 
 import (
+	"context"
 	"example.com/micro/action"
+	"example.com/micro/api"
 	"example.com/micro/client/login"
+	"example.com/micro/config"
+	"example.com/micro/journey"
+	"example.com/micro/metrics"
+	"example.com/micro/model"
+	"example.com/micro/secret"
 	"fmt"
 	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk"
-	"log"
-	"net/http"
+	"go.mongodb.org/mongo-driver/mongo"
 	"os"
+	"os/signal"
+	"syscall"
+	_ "time/tzdata"
 )
 
 var app *sdk.App
 
+// initDatabase prepares the collections and the accounts a login run needs.
+func initDatabase(database *mongo.Database) error {
+	model.InitAccount(database)
+	model.InitLoginRun(database)
+	model.InitSessionToken(database)
+	model.InitJourneyRun(database)
+	model.InitLease(database)
+	model.InitRunLock(database)
+	model.InitAccountReload(database)
+
+	if config.AccountFile != "" {
+		if err := action.SyncAccountFile(); err != nil {
+			return fmt.Errorf("error when syncing account file: %w", err)
+		}
+	} else if err := action.ImportAccountSeed(accountSeedFile()); err != nil {
+		return fmt.Errorf("error when importing account seed: %w", err)
+	}
+	if err := action.CheckStoredPasswords(); err != nil {
+		return fmt.Errorf("refusing to start: %w", err)
+	}
+	return nil
+}
+
+func onDBConnected(database *mongo.Database) error {
+	if err := initDatabase(database); err != nil {
+		exit(exitConfig, err)
+	}
+	if err := action.InitScheduleAutoLogin(database); err != nil {
+		exit(exitConfig, "Error when loading AUTO_LOGIN schedule: ", err)
+	}
+	action.InitJourneys()
+	return nil
+}
+
+// accountSeedFile is ACCOUNT_SEED_FILE, or ./account.json.
+func accountSeedFile() string {
+	if path := os.Getenv("ACCOUNT_SEED_FILE"); path != "" {
+		return path
+	}
+	return "./account.json"
+}
+
+// loadRunConfig reads the configuration files a login run needs.
+func loadRunConfig() error {
+	if err := config.CheckSettings(); err != nil {
+		return err
+	}
+	if err := config.LoadEnvironments(""); err != nil {
+		return fmt.Errorf("environments: %w", err)
+	}
+	login.InitLoginClients()
+	if err := config.LoadWebhooks(""); err != nil {
+		return fmt.Errorf("webhooks: %w", err)
+	}
+	if config.AccountFile != "" {
+		file, err := action.NewAccountFile(config.AccountFile)
+		if err != nil {
+			return fmt.Errorf("account file: %w", err)
+		}
+		action.SetAccountFile(file)
+	}
+	return nil
+}
+
 func main() {
 
 	app = sdk.NewApp("Autologin project")
 
-	login.InitLoginStgClient()
-	login.InitLoginDevClient()
+	if err := secret.Init(); err != nil {
+		exit(exitConfig, "Error when loading master key: ", err)
+	}
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
+	if err := loadRunConfig(); err != nil {
+		exit(exitConfig, "Error when loading configuration: ", err)
+	}
+	if err := config.LoadAPIKeys(); err != nil {
+		exit(exitConfig, "Error when loading API keys: ", err)
+	}
+	if err := config.LoadAccess(""); err != nil {
+		exit(exitConfig, "Error when loading access policy: ", err)
+	}
+	if err := api.InitAccess(); err != nil {
+		exit(exitConfig, "Refusing to start: ", err)
+	}
+	journeys, err := journey.LoadDir(config.JourneyDir)
+	if err == nil {
+		err = action.SetJourneys(journeys)
+	}
+	if err != nil {
+		exit(exitConfig, "Error when loading journeys: ", err)
+	}
 
-	var work = app.SetupWorker()
-	work = work.SetDelay(1)
-	work = work.SetRepeatPeriod(24 * 60 * 60)
-	work = work.SetTask(action.AutoLoginTask)
+	dbConfig, err := config.LoadDatabase()
+	if err != nil {
+		exit(exitConfig, "Error when loading database configuration: ", err)
+	}
+	app.SetupDBClient(dbConfig, onDBConnected)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	server, _ := app.SetupAPIServer("HTTP")
+	metrics.Default.OnCollect(action.CollectMetrics)
+	if err = api.SetRoutes(server); err != nil {
+		exit(exitConfig, "Error when registering API routes: ", err)
 	}
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", port), nil))
+	server.Expose(sdk.ParseInt(os.Getenv("PORT"), 8080))
 
-	app.Launch()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	app.OnAllDBConnected(func() {
+		action.ScheduleDb.Start()
+		go action.WatchRunLock(ctx)
+		go action.WatchAccountSource(ctx)
+	})
+
+	launched := make(chan error, 1)
+	go func() {
+		launched <- app.Launch()
+	}()
+
+	select {
+	case err = <-launched:
+		if err != nil {
+			exit(exitFailed, "Error when launching app: ", err)
+		}
+	case <-ctx.Done():
+		shutdown(server)
+	}
 }
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := &Registry{}
+	attempts := r.NewCounterVec("login_attempts_total", "Login attempts.", "environment", "class")
+	latency := r.NewHistogramVec("login_duration_seconds", "Login latency.", []float64{0.1, 1}, "environment")
+	paused := r.NewGaugeVec("paused_accounts", "Paused accounts.")
+
+	attempts.Inc("stg", "SUCCESS")
+	attempts.Inc("stg", "SUCCESS")
+	attempts.Inc(`d"e\v`, "LOCKED")
+	latency.Observe(0.05, "stg")
+	latency.Observe(0.1, "stg")
+	latency.Observe(3, "stg")
+	r.OnCollect(func() { paused.Set(2) })
+
+	var out strings.Builder
+	if err := r.WriteText(&out); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	want := `# HELP login_attempts_total Login attempts.
+# TYPE login_attempts_total counter
+login_attempts_total{environment="d\"e\\v",class="LOCKED"} 1
+login_attempts_total{environment="stg",class="SUCCESS"} 2
+# HELP login_duration_seconds Login latency.
+# TYPE login_duration_seconds histogram
+login_duration_seconds_bucket{environment="stg",le="0.1"} 2
+login_duration_seconds_bucket{environment="stg",le="1"} 2
+login_duration_seconds_bucket{environment="stg",le="+Inf"} 3
+login_duration_seconds_sum{environment="stg"} 3.15
+login_duration_seconds_count{environment="stg"} 3
+# HELP paused_accounts Paused accounts.
+# TYPE paused_accounts gauge
+paused_accounts 2
+`
+	if out.String() != want {
+		t.Errorf("WriteText =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestHandlerContentType(t *testing.T) {
+	r := &Registry{}
+	r.NewGaugeVec("up", "Up.").Set(1)
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); got != ContentType {
+		t.Errorf("Content-Type = %q, want %q", got, ContentType)
+	}
+	if !strings.Contains(rec.Body.String(), "\nup 1\n") {
+		t.Errorf("body = %q, want the up gauge", rec.Body.String())
+	}
+}
@@ -1,13 +1,112 @@
 package model
 
+import (
+	"example.com/micro/model/core/obj"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
 const (
 	STG = "stg"
 	DEV = "dev"
 )
 
+// AccountStatusEnum ...
+type AccountStatusEnum struct {
+	Active   string
+	Disabled string
+	Paused   string
+}
+
+// AccountStatus enumerates the lifecycle states of a registered account.
+// Paused accounts were stopped by the service after consecutive credential
+// failures; like disabled ones they are skipped until re-enabled.
+var AccountStatus = &AccountStatusEnum{
+	Active:   "ACTIVE",
+	Disabled: "DISABLED",
+	Paused:   "PAUSED",
+}
+
+// Probe is a request sent with the session token right after a successful
+// login to check the account is usable. API is "<METHOD>::<path>" on the
+// account's environment. ExpectCode defaults to 200 and ExpectStatus to "OK";
+// Assert maps dotted JSON paths of the response (e.g. "data.0.isActive") to
+// their expected value.
+type Probe struct {
+	Name         string                 `json:"name" bson:"name,omitempty"`
+	API          string                 `json:"api" bson:"api,omitempty"`
+	Params       map[string]string      `json:"params,omitempty" bson:"params,omitempty"`
+	Body         interface{}            `json:"body,omitempty" bson:"body,omitempty"`
+	ExpectCode   int                    `json:"expectCode,omitempty" bson:"expect_code,omitempty"`
+	ExpectStatus string                 `json:"expectStatus,omitempty" bson:"expect_status,omitempty"`
+	Assert       map[string]interface{} `json:"assert,omitempty" bson:"assert,omitempty"`
+}
+
 type Account struct {
-	Username   string `json:"username"`
-	Password   string `json:"password"`
-	Type       string `json:"type"`
-	DomainType string `json:"domainType"`
+	ID              *primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	CreatedTime     *time.Time          `json:"createdTime,omitempty" bson:"created_time,omitempty"`
+	LastUpdatedTime *time.Time          `json:"lastUpdatedTime,omitempty" bson:"last_updated_time,omitempty"`
+
+	Username   string `json:"username" bson:"username,omitempty"`
+	Password   string `json:"password,omitempty" bson:"password,omitempty"`
+	Type       string `json:"type" bson:"type,omitempty"`
+	DomainType string `json:"domainType" bson:"domain_type,omitempty"`
+	Status     string `json:"status,omitempty" bson:"status,omitempty"`
+	Owner      string `json:"owner,omitempty" bson:"owner,omitempty"`
+
+	// Tags let lease requests pick an account with given traits, e.g.
+	// "has-debt" or "vip".
+	Tags []string `json:"tags,omitempty" bson:"tags,omitempty"`
+
+	// TOTPSeed is the base32 seed of accounts behind two-factor login,
+	// sealed like Password.
+	TOTPSeed string `json:"totpSeed,omitempty" bson:"totp_seed,omitempty"`
+
+	Probes []*Probe `json:"probes,omitempty" bson:"probes,omitempty"`
+
+	LastStatus      string     `json:"lastStatus,omitempty" bson:"last_status,omitempty"`
+	LastAttemptTime *time.Time `json:"lastAttemptTime,omitempty" bson:"last_attempt_time,omitempty"`
+
+	// ConsecutiveFailures counts credential failures since the last success.
+	// PausedTime and PauseReason describe the last automatic pause.
+	ConsecutiveFailures *int       `json:"consecutiveFailures,omitempty" bson:"consecutive_failures,omitempty"`
+	PausedTime          *time.Time `json:"pausedTime,omitempty" bson:"paused_time,omitempty"`
+	PauseReason         string     `json:"pauseReason,omitempty" bson:"pause_reason,omitempty"`
+
+	// LeaseID identifies the last lease of the account, which is in force
+	// until LeaseExpiredTime. It is the holder's handle to renew and release
+	// the lease, so it is never returned with the account.
+	LeaseID          string     `json:"-" bson:"lease_id,omitempty"`
+	LeaseHolder      string     `json:"leaseHolder,omitempty" bson:"lease_holder,omitempty"`
+	LeaseExpiredTime *time.Time `json:"leaseExpiredTime,omitempty" bson:"lease_expired_time,omitempty"`
+}
+
+// Leased reports whether the account is checked out at now.
+func (a *Account) Leased(now time.Time) bool {
+	return a.LeaseExpiredTime != nil && a.LeaseExpiredTime.After(now)
+}
+
+var DBAccount = &db.Instance{
+	ColName:        "account",
+	TemplateObject: &Account{},
+}
+
+func InitAccount(database *mongo.Database) {
+	DBAccount.ApplyDatabase(database)
+	DBAccount.CreateIndex(bson.D{
+		{Key: "domain_type", Value: 1},
+		{Key: "username", Value: 1},
+	}, &options.IndexOptions{
+		Background: obj.WithBool(true),
+		Unique:     obj.WithBool(true),
+	})
+	DBAccount.CreateIndex(bson.D{
+		{Key: "status", Value: 1},
+	}, &options.IndexOptions{
+		Background: obj.WithBool(true),
+	})
 }
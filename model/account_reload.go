@@ -0,0 +1,58 @@
+package model
+
+import (
+	"example.com/micro/model/core/obj"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// ReloadStatusEnum ...
+type ReloadStatusEnum struct {
+	Applied  string
+	Rejected string
+}
+
+// ReloadStatus enumerates the outcomes of an account file reload. A rejected
+// file leaves the accounts of the previous version in use.
+var ReloadStatus = &ReloadStatusEnum{
+	Applied:  "APPLIED",
+	Rejected: "REJECTED",
+}
+
+// AccountReload records a new version of the account file, applied or not.
+// Version is a digest of the file content; Added, Removed and Changed count
+// accounts by environment and username against PreviousVersion.
+type AccountReload struct {
+	ID          *primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	CreatedTime *time.Time          `json:"createdTime,omitempty" bson:"created_time,omitempty"`
+
+	Source          string     `json:"source" bson:"source,omitempty"`
+	Version         string     `json:"version" bson:"version,omitempty"`
+	PreviousVersion string     `json:"previousVersion,omitempty" bson:"previous_version,omitempty"`
+	Hostname        string     `json:"hostname,omitempty" bson:"hostname,omitempty"`
+	Status          string     `json:"status" bson:"status,omitempty"`
+	ReloadTime      *time.Time `json:"reloadTime,omitempty" bson:"reload_time,omitempty"`
+	Total           *int       `json:"total,omitempty" bson:"total,omitempty"`
+	Added           *int       `json:"added,omitempty" bson:"added,omitempty"`
+	Removed         *int       `json:"removed,omitempty" bson:"removed,omitempty"`
+	Changed         *int       `json:"changed,omitempty" bson:"changed,omitempty"`
+	Problems        []string   `json:"problems,omitempty" bson:"problems,omitempty"`
+}
+
+var DBAccountReload = &db.Instance{
+	ColName:        "account_reload",
+	TemplateObject: &AccountReload{},
+}
+
+func InitAccountReload(database *mongo.Database) {
+	DBAccountReload.ApplyDatabase(database)
+	DBAccountReload.CreateIndex(bson.D{
+		{Key: "reload_time", Value: -1},
+	}, &options.IndexOptions{
+		Background: obj.WithBool(true),
+	})
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"example.com/micro/journey"
+	"example.com/micro/model/core/obj"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// JourneyRun is one execution of a synthetic journey with its step results.
+// Status takes the RunStatus values.
+type JourneyRun struct {
+	ID              *primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	CreatedTime     *time.Time          `json:"createdTime,omitempty" bson:"created_time,omitempty"`
+	LastUpdatedTime *time.Time          `json:"lastUpdatedTime,omitempty" bson:"last_updated_time,omitempty"`
+
+	RunID       string                `json:"runId" bson:"run_id,omitempty"`
+	Journey     string                `json:"journey" bson:"journey,omitempty"`
+	Environment string                `json:"environment" bson:"environment,omitempty"`
+	Trigger     string                `json:"trigger" bson:"trigger,omitempty"`
+	Hostname    string                `json:"hostname,omitempty" bson:"hostname,omitempty"`
+	Status      string                `json:"status" bson:"status,omitempty"`
+	Message     string                `json:"message,omitempty" bson:"message,omitempty"`
+	StartTime   *time.Time            `json:"startTime,omitempty" bson:"start_time,omitempty"`
+	EndTime     *time.Time            `json:"endTime,omitempty" bson:"end_time,omitempty"`
+	Steps       []*journey.StepResult `json:"steps,omitempty" bson:"steps,omitempty"`
+}
+
+var DBJourneyRun = &db.Instance{
+	ColName:        "journey_run",
+	TemplateObject: &JourneyRun{},
+}
+
+func InitJourneyRun(database *mongo.Database) {
+	DBJourneyRun.ApplyDatabase(database)
+	DBJourneyRun.CreateIndex(bson.D{
+		{Key: "run_id", Value: 1},
+	}, &options.IndexOptions{
+		Background: obj.WithBool(true),
+		Unique:     obj.WithBool(true),
+	})
+	DBJourneyRun.CreateIndex(bson.D{
+		{Key: "journey", Value: 1},
+		{Key: "start_time", Value: -1},
+	}, &options.IndexOptions{
+		Background: obj.WithBool(true),
+	})
+}
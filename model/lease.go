@@ -0,0 +1,87 @@
+package model
+
+import (
+	"example.com/micro/model/core/obj"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// LeaseStatusEnum ...
+type LeaseStatusEnum struct {
+	Active   string
+	Released string
+	Expired  string
+}
+
+// LeaseStatus enumerates the states of an account lease.
+var LeaseStatus = &LeaseStatusEnum{
+	Active:   "ACTIVE",
+	Released: "RELEASED",
+	Expired:  "EXPIRED",
+}
+
+// LeaseGrantEnum ...
+type LeaseGrantEnum struct {
+	Credentials string
+	Token       string
+}
+
+// LeaseGrant enumerates what a lease hands out: the account password, or a
+// session token from a fresh login.
+var LeaseGrant = &LeaseGrantEnum{
+	Credentials: "CREDENTIALS",
+	Token:       "TOKEN",
+}
+
+// Lease is the history of one exclusive checkout of an account. The account
+// itself carries the lease in force; this record keeps who held it and how it
+// ended. Password and Token are only filled in the response to the checkout.
+type Lease struct {
+	ID              *primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	CreatedTime     *time.Time          `json:"createdTime,omitempty" bson:"created_time,omitempty"`
+	LastUpdatedTime *time.Time          `json:"lastUpdatedTime,omitempty" bson:"last_updated_time,omitempty"`
+
+	LeaseID      string     `json:"leaseId" bson:"lease_id,omitempty"`
+	Username     string     `json:"username" bson:"username,omitempty"`
+	DomainType   string     `json:"domainType" bson:"domain_type,omitempty"`
+	Type         string     `json:"type,omitempty" bson:"type,omitempty"`
+	Holder       string     `json:"holder,omitempty" bson:"holder,omitempty"`
+	Grant        string     `json:"grant,omitempty" bson:"grant,omitempty"`
+	Status       string     `json:"status" bson:"status,omitempty"`
+	StartTime    *time.Time `json:"startTime,omitempty" bson:"start_time,omitempty"`
+	ExpiredTime  *time.Time `json:"expiredTime,omitempty" bson:"expired_time,omitempty"`
+	ReleasedTime *time.Time `json:"releasedTime,omitempty" bson:"released_time,omitempty"`
+
+	Password string `json:"password,omitempty" bson:"-"`
+	Token    string `json:"token,omitempty" bson:"-"`
+}
+
+var DBLease = &db.Instance{
+	ColName:        "lease",
+	TemplateObject: &Lease{},
+}
+
+func InitLease(database *mongo.Database) {
+	DBLease.ApplyDatabase(database)
+	DBLease.CreateIndex(bson.D{
+		{Key: "lease_id", Value: 1},
+	}, &options.IndexOptions{
+		Background: obj.WithBool(true),
+		Unique:     obj.WithBool(true),
+	})
+	DBLease.CreateIndex(bson.D{
+		{Key: "status", Value: 1},
+		{Key: "expired_time", Value: 1},
+	}, &options.IndexOptions{
+		Background: obj.WithBool(true),
+	})
+	DBAccount.CreateIndex(bson.D{
+		{Key: "lease_id", Value: 1},
+	}, &options.IndexOptions{
+		Background: obj.WithBool(true),
+	})
+}
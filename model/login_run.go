@@ -0,0 +1,178 @@
+package model
+
+import (
+	"example.com/micro/model/core/obj"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// RunTriggerEnum ...
+type RunTriggerEnum struct {
+	Worker   string
+	Schedule string
+	API      string
+	Refresh  string
+	Job      string
+	CLI      string
+}
+
+// RunTrigger enumerates what started a login run.
+var RunTrigger = &RunTriggerEnum{
+	Worker:   "WORKER",
+	Schedule: "SCHEDULE",
+	API:      "API",
+	Refresh:  "TOKEN_REFRESH",
+	Job:      "JOB",
+	CLI:      "CLI",
+}
+
+// RunStatusEnum ...
+type RunStatusEnum struct {
+	Running string
+	Success string
+	Partial string
+	Failed  string
+}
+
+// RunStatus enumerates the states of a login run.
+var RunStatus = &RunStatusEnum{
+	Running: "RUNNING",
+	Success: "SUCCESS",
+	Partial: "PARTIAL_FAILURE",
+	Failed:  "FAILED",
+}
+
+// AttemptStatusEnum ...
+type AttemptStatusEnum struct {
+	Success      string
+	Failed       string
+	VerifyFailed string
+	ConfigError  string
+}
+
+// AttemptStatus enumerates the outcomes of one account login within a run.
+// VerifyFailed is a successful login whose session failed a probe.
+var AttemptStatus = &AttemptStatusEnum{
+	Success:      "SUCCESS",
+	Failed:       "FAILED",
+	VerifyFailed: "VERIFY_FAILED",
+	ConfigError:  "CONFIG_ERROR",
+}
+
+// FailureClassEnum ...
+type FailureClassEnum struct {
+	BadCredentials string
+	Locked         string
+	RateLimited    string
+	ServerError    string
+	Timeout        string
+	Network        string
+	ClientError    string
+}
+
+// FailureClass enumerates why a login call did not succeed.
+var FailureClass = &FailureClassEnum{
+	BadCredentials: "BAD_CREDENTIALS",
+	Locked:         "LOCKED",
+	RateLimited:    "RATE_LIMITED",
+	ServerError:    "SERVER_ERROR",
+	Timeout:        "TIMEOUT",
+	Network:        "NETWORK",
+	ClientError:    "CLIENT_ERROR",
+}
+
+// LoginRun is one pass of the auto-login job over the account registry.
+// LockToken is the fencing token of the run lock its executor holds; Resumed
+// marks a run another instance took over after its executor died.
+type LoginRun struct {
+	ID              *primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	CreatedTime     *time.Time          `json:"createdTime,omitempty" bson:"created_time,omitempty"`
+	LastUpdatedTime *time.Time          `json:"lastUpdatedTime,omitempty" bson:"last_updated_time,omitempty"`
+
+	RunID       string     `json:"runId" bson:"run_id,omitempty"`
+	Trigger     string     `json:"trigger" bson:"trigger,omitempty"`
+	Environment string     `json:"environment,omitempty" bson:"environment,omitempty"`
+	Username    string     `json:"username,omitempty" bson:"username,omitempty"`
+	AccountType string     `json:"accountType,omitempty" bson:"account_type,omitempty"`
+	Hostname    string     `json:"hostname,omitempty" bson:"hostname,omitempty"`
+	Status      string     `json:"status" bson:"status,omitempty"`
+	Message     string     `json:"message,omitempty" bson:"message,omitempty"`
+	StartTime   *time.Time `json:"startTime,omitempty" bson:"start_time,omitempty"`
+	EndTime     *time.Time `json:"endTime,omitempty" bson:"end_time,omitempty"`
+	LockToken   int64      `json:"lockToken,omitempty" bson:"lock_token,omitempty"`
+	Resumed     bool       `json:"resumed,omitempty" bson:"resumed,omitempty"`
+
+	Total       *int `json:"total,omitempty" bson:"total,omitempty"`
+	Success     *int `json:"success,omitempty" bson:"success,omitempty"`
+	Fail        *int `json:"fail,omitempty" bson:"fail,omitempty"`
+	VerifyFail  *int `json:"verifyFail,omitempty" bson:"verify_fail,omitempty"`
+	ConfigError *int `json:"configError,omitempty" bson:"config_error,omitempty"`
+	Skipped     *int `json:"skipped,omitempty" bson:"skipped,omitempty"`
+	Paused      *int `json:"paused,omitempty" bson:"paused,omitempty"`
+	Leased      *int `json:"leased,omitempty" bson:"leased,omitempty"`
+}
+
+// LoginAttempt is the outcome of one account login within a run.
+type LoginAttempt struct {
+	ID          *primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	CreatedTime *time.Time          `json:"createdTime,omitempty" bson:"created_time,omitempty"`
+
+	RunID       string     `json:"runId" bson:"run_id,omitempty"`
+	Username    string     `json:"username" bson:"username,omitempty"`
+	Type        string     `json:"type,omitempty" bson:"type,omitempty"`
+	DomainType  string     `json:"domainType" bson:"domain_type,omitempty"`
+	Status      string     `json:"status" bson:"status,omitempty"`
+	HTTPCode    int        `json:"httpCode,omitempty" bson:"http_code,omitempty"`
+	ErrorCode   string     `json:"errorCode,omitempty" bson:"error_code,omitempty"`
+	Message     string     `json:"message,omitempty" bson:"message,omitempty"`
+	Class       string     `json:"class,omitempty" bson:"class,omitempty"`
+	LatencyMs   int64      `json:"latencyMs" bson:"latency_ms"`
+	Retries     int        `json:"retries" bson:"retries"`
+	StartTime   *time.Time `json:"startTime,omitempty" bson:"start_time,omitempty"`
+	Change      string     `json:"change,omitempty" bson:"change,omitempty"`
+	FailedProbe string     `json:"failedProbe,omitempty" bson:"failed_probe,omitempty"`
+	Paused      bool       `json:"paused,omitempty" bson:"paused,omitempty"`
+}
+
+var DBLoginRun = &db.Instance{
+	ColName:        "login_run",
+	TemplateObject: &LoginRun{},
+}
+
+var DBLoginAttempt = &db.Instance{
+	ColName:        "login_attempt",
+	TemplateObject: &LoginAttempt{},
+}
+
+func InitLoginRun(database *mongo.Database) {
+	DBLoginRun.ApplyDatabase(database)
+	DBLoginRun.CreateIndex(bson.D{
+		{Key: "run_id", Value: 1},
+	}, &options.IndexOptions{
+		Background: obj.WithBool(true),
+		Unique:     obj.WithBool(true),
+	})
+	DBLoginRun.CreateIndex(bson.D{
+		{Key: "start_time", Value: -1},
+	}, &options.IndexOptions{
+		Background: obj.WithBool(true),
+	})
+
+	DBLoginAttempt.ApplyDatabase(database)
+	DBLoginAttempt.CreateIndex(bson.D{
+		{Key: "run_id", Value: 1},
+	}, &options.IndexOptions{
+		Background: obj.WithBool(true),
+	})
+	DBLoginAttempt.CreateIndex(bson.D{
+		{Key: "domain_type", Value: 1},
+		{Key: "username", Value: 1},
+		{Key: "start_time", Value: -1},
+	}, &options.IndexOptions{
+		Background: obj.WithBool(true),
+	})
+}
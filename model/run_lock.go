@@ -0,0 +1,46 @@
+package model
+
+import (
+	"example.com/micro/model/core/obj"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// LoginRunLock names the lock held by the instance executing a login run.
+const LoginRunLock = "LOGIN_RUN"
+
+// RunLock elects the one instance allowed to execute a login run. Token is
+// a fencing token increased on every acquisition, so a holder that lost the
+// lock cannot write over its successor. RunID is the run under way, empty
+// once released; a lock that expired with a RunID was abandoned mid-run.
+type RunLock struct {
+	ID              *primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	CreatedTime     *time.Time          `json:"createdTime,omitempty" bson:"created_time,omitempty"`
+	LastUpdatedTime *time.Time          `json:"lastUpdatedTime,omitempty" bson:"last_updated_time,omitempty"`
+
+	Name          string     `json:"name" bson:"name,omitempty"`
+	Holder        string     `json:"holder,omitempty" bson:"holder,omitempty"`
+	Token         int64      `json:"token" bson:"token,omitempty"`
+	RunID         string     `json:"runId,omitempty" bson:"run_id,omitempty"`
+	HeartbeatTime *time.Time `json:"heartbeatTime,omitempty" bson:"heartbeat_time,omitempty"`
+	ExpiredTime   *time.Time `json:"expiredTime,omitempty" bson:"expired_time,omitempty"`
+}
+
+var DBRunLock = &db.Instance{
+	ColName:        "run_lock",
+	TemplateObject: &RunLock{},
+}
+
+func InitRunLock(database *mongo.Database) {
+	DBRunLock.ApplyDatabase(database)
+	DBRunLock.CreateIndex(bson.D{
+		{Key: "name", Value: 1},
+	}, &options.IndexOptions{
+		Background: obj.WithBool(true),
+		Unique:     obj.WithBool(true),
+	})
+}
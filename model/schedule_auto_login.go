@@ -2,34 +2,103 @@ package model
 
 import (
 	"example.com/micro/model/core/obj"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/common"
 	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/db"
-	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/schedule"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"regexp"
 	"time"
 )
 
 const (
-	AutoLogin         = "AUTO_LOGIN"
-	TimeToDeleteDraft = 24 * 60 * 60
+	AutoLogin    = "AUTO_LOGIN"
+	TokenRefresh = "TOKEN_REFRESH"
 )
 
+// Schedule mirrors the SDK schedule.Config document and adds the cron
+// expression and time zone the next run is computed from. A paused topic is
+// parked with a next run far in the future. LastResult is the last entry of
+// the scheduler history, filled when listing.
+type Schedule struct {
+	ID              *primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	LastUpdatedTime *time.Time          `json:"lastUpdatedTime,omitempty" bson:"last_updated_time,omitempty"`
+	CreatedTime     *time.Time          `json:"createdTime,omitempty" bson:"created_time,omitempty"`
+
+	Topic    string     `json:"topic" bson:"topic,omitempty"`
+	NextRun  *time.Time `json:"nextRun,omitempty" bson:"next_run,omitempty"`
+	Cron     string     `json:"cron,omitempty" bson:"cron,omitempty"`
+	TimeZone string     `json:"timeZone,omitempty" bson:"time_zone,omitempty"`
+	Paused   *bool      `json:"paused,omitempty" bson:"paused,omitempty"`
+
+	LastResult string `json:"lastResult,omitempty" bson:"-"`
+}
+
 var DBSchedule = &db.Instance{
 	ColName:        "schedule_auto_login",
-	TemplateObject: &schedule.Config{},
+	TemplateObject: &Schedule{},
 }
 
 func InitSchedule(database *mongo.Database) {
 	DBSchedule.ApplyDatabase(database)
 	DBSchedule.CreateIndex(bson.D{
-		{"topic", 1},
+		{Key: "topic", Value: 1},
 	}, &options.IndexOptions{
 		Background: obj.WithBool(true),
 		Unique:     obj.WithBool(true),
 	})
-	DBSchedule.Create(&schedule.Config{
-		Topic:   AutoLogin,
-		NextRun: obj.WithTime(time.Now().Add(24 * time.Hour)),
+}
+
+// EnsureSchedule creates the topic when it does not exist yet. A topic with
+// a cron expression also gets it set when it was created before schedules
+// had one.
+func EnsureSchedule(schedule *Schedule) {
+	DBSchedule.Create(schedule)
+	if schedule.Cron == "" {
+		return
+	}
+	DBSchedule.UpdateOne(bson.M{
+		"topic": schedule.Topic,
+		"cron":  bson.M{"$exists": false},
+	}, Schedule{
+		NextRun:  schedule.NextRun,
+		Cron:     schedule.Cron,
+		TimeZone: schedule.TimeZone,
+	})
+}
+
+// DeleteSchedules removes the topics starting with prefix except the ones in
+// keep, e.g. the topics of journeys whose definition is gone.
+func DeleteSchedules(prefix string, keep []string) *common.APIResponse {
+	return DBSchedule.Delete(bson.M{
+		"topic": bson.M{"$regex": "^" + regexp.QuoteMeta(prefix), "$nin": keep},
+	})
+}
+
+// SetScheduleCron replaces the cron expression and time zone of a topic whose
+// schedule is owned by a file, rescheduling it when they changed unless it is
+// paused.
+func SetScheduleCron(topic, cronExpr, timeZone string, nextRun time.Time) {
+	changed := []bson.M{
+		{"cron": bson.M{"$ne": cronExpr}},
+		{"time_zone": bson.M{"$ne": timeZone}},
+	}
+	DBSchedule.UpdateOne(bson.M{
+		"topic":  topic,
+		"paused": bson.M{"$ne": true},
+		"$or":    changed,
+	}, Schedule{
+		NextRun:  obj.WithTime(nextRun),
+		Cron:     cronExpr,
+		TimeZone: timeZone,
+	})
+	DBSchedule.UpdateOne(bson.M{
+		"topic":  topic,
+		"paused": true,
+		"$or":    changed,
+	}, Schedule{
+		Cron:     cronExpr,
+		TimeZone: timeZone,
 	})
 }
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"example.com/micro/model/core/obj"
+	"gitlab.com/thuocsi.vn-sdk/go-sdk/sdk/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// SessionToken is the latest session captured for an account. Token is
+// sealed with the secret package. RefreshTime is when the account is due for
+// a new login, ahead of ExpiredTime.
+type SessionToken struct {
+	ID              *primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	CreatedTime     *time.Time          `json:"createdTime,omitempty" bson:"created_time,omitempty"`
+	LastUpdatedTime *time.Time          `json:"lastUpdatedTime,omitempty" bson:"last_updated_time,omitempty"`
+
+	Username     string     `json:"username" bson:"username,omitempty"`
+	DomainType   string     `json:"domainType" bson:"domain_type,omitempty"`
+	Token        string     `json:"token" bson:"token,omitempty"`
+	ExpiredTime  *time.Time `json:"expiredTime,omitempty" bson:"expired_time,omitempty"`
+	RefreshTime  *time.Time `json:"refreshTime,omitempty" bson:"refresh_time,omitempty"`
+	CapturedTime *time.Time `json:"capturedTime,omitempty" bson:"captured_time,omitempty"`
+	RunID        string     `json:"runId,omitempty" bson:"run_id,omitempty"`
+}
+
+var DBSessionToken = &db.Instance{
+	ColName:        "session_token",
+	TemplateObject: &SessionToken{},
+}
+
+func InitSessionToken(database *mongo.Database) {
+	DBSessionToken.ApplyDatabase(database)
+	DBSessionToken.CreateIndex(bson.D{
+		{Key: "domain_type", Value: 1},
+		{Key: "username", Value: 1},
+	}, &options.IndexOptions{
+		Background: obj.WithBool(true),
+		Unique:     obj.WithBool(true),
+	})
+	DBSessionToken.CreateIndex(bson.D{
+		{Key: "refresh_time", Value: 1},
+	}, &options.IndexOptions{
+		Background: obj.WithBool(true),
+	})
+}
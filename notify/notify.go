@@ -0,0 +1,181 @@
+// Package notify posts login run summaries to chat webhooks.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"example.com/micro/config"
+	"example.com/micro/secret"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	StateFailing   = "NEWLY_FAILING"
+	StateRecovered = "RECOVERED"
+	StatePaused    = "PAUSED"
+)
+
+var (
+	httpClient = &http.Client{Timeout: 10 * time.Second}
+	slackUser  = regexp.MustCompile(`^[UW][A-Z0-9]{6,}$`)
+)
+
+// Change is one account whose login state flipped during a run. Failures is
+// its count of consecutive credential failures.
+type Change struct {
+	Username   string `json:"username"`
+	DomainType string `json:"domainType"`
+	Type       string `json:"type,omitempty"`
+	Owner      string `json:"owner,omitempty"`
+	State      string `json:"state"`
+	HTTPCode   int    `json:"httpCode,omitempty"`
+	ErrorCode  string `json:"errorCode,omitempty"`
+	Message    string `json:"message,omitempty"`
+	Failures   int    `json:"failures,omitempty"`
+}
+
+// Summary describes a run and the state changes it produced.
+type Summary struct {
+	RunID       string    `json:"runId"`
+	Trigger     string    `json:"trigger"`
+	Environment string    `json:"environment,omitempty"`
+	Status      string    `json:"status"`
+	Total       int       `json:"total"`
+	Success     int       `json:"success"`
+	Fail        int       `json:"fail"`
+	VerifyFail  int       `json:"verifyFail"`
+	ConfigError int       `json:"configError"`
+	Failing     []*Change `json:"failing"`
+	Recovered   []*Change `json:"recovered"`
+	Paused      []*Change `json:"paused"`
+}
+
+// HasChanges reports whether the summary is worth a notification.
+func (s *Summary) HasChanges() bool {
+	return len(s.Failing) > 0 || len(s.Recovered) > 0 || len(s.Paused) > 0
+}
+
+// Send posts the summary to every webhook and reports the ones that failed.
+func Send(hooks []*config.Webhook, summary *Summary) error {
+	var failed []string
+	for _, hook := range hooks {
+		if err := post(hook, summary); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", hookName(hook), err.Error()))
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func hookName(hook *config.Webhook) string {
+	if hook.Name != "" {
+		return hook.Name
+	}
+	return hook.Kind
+}
+
+func post(hook *config.Webhook, summary *Summary) error {
+	url, err := secret.Decrypt(hook.URL)
+	if err != nil {
+		return err
+	}
+
+	var payload interface{}
+	switch hook.Kind {
+	case config.WebhookSlack:
+		payload = map[string]string{"text": render(summary, slackMention)}
+	case config.WebhookGoogleChat:
+		payload = map[string]string{"text": render(summary, chatMention)}
+	default:
+		payload = summary
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Post(url, "application/json; charset=UTF-8", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func render(s *Summary, mention func(string) string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*Auto-login run %s* (%s", s.RunID, s.Trigger)
+	if s.Environment != "" {
+		fmt.Fprintf(&b, ", %s", s.Environment)
+	}
+	fmt.Fprintf(&b, "): %s, %d/%d succeeded", s.Status, s.Success, s.Total)
+	if s.VerifyFail > 0 {
+		fmt.Fprintf(&b, ", %d failed verification", s.VerifyFail)
+	}
+	if s.ConfigError > 0 {
+		fmt.Fprintf(&b, ", %d configuration error(s)", s.ConfigError)
+	}
+
+	if len(s.Failing) > 0 {
+		b.WriteString("\nNewly failing:")
+		for _, c := range s.Failing {
+			fmt.Fprintf(&b, "\n• %s__%s", c.DomainType, c.Username)
+			if c.ErrorCode != "" || c.HTTPCode != 0 {
+				fmt.Fprintf(&b, " (%d %s)", c.HTTPCode, c.ErrorCode)
+			}
+			if c.Message != "" {
+				fmt.Fprintf(&b, " %s", c.Message)
+			}
+			if c.Owner != "" {
+				fmt.Fprintf(&b, " %s", mention(c.Owner))
+			}
+		}
+	}
+	if len(s.Paused) > 0 {
+		b.WriteString("\nPaused until re-enabled:")
+		for _, c := range s.Paused {
+			fmt.Fprintf(&b, "\n• %s__%s after %d consecutive failures (%d %s)", c.DomainType, c.Username, c.Failures, c.HTTPCode, c.ErrorCode)
+			if c.Owner != "" {
+				fmt.Fprintf(&b, " %s", mention(c.Owner))
+			}
+		}
+	}
+	if len(s.Recovered) > 0 {
+		b.WriteString("\nRecovered:")
+		for _, c := range s.Recovered {
+			fmt.Fprintf(&b, "\n• %s__%s", c.DomainType, c.Username)
+			if c.Owner != "" {
+				fmt.Fprintf(&b, " %s", mention(c.Owner))
+			}
+		}
+	}
+	return b.String()
+}
+
+// slackMention turns a Slack member ID into a mention and leaves names as is.
+func slackMention(owner string) string {
+	if slackUser.MatchString(owner) {
+		return "<@" + owner + ">"
+	}
+	return "@" + strings.TrimPrefix(owner, "@")
+}
+
+// chatMention mentions a Google Chat user by email or users/ID.
+func chatMention(owner string) string {
+	if strings.Contains(owner, "@") || strings.HasPrefix(owner, "users/") {
+		return "<users/" + strings.TrimPrefix(owner, "users/") + ">"
+	}
+	return owner
+}
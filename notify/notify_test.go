@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"encoding/json"
+	"example.com/micro/config"
+	"example.com/micro/secret"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSendToWebhookStandIn(t *testing.T) {
+	t.Setenv("INSECURE_DEV", "true")
+	if err := secret.Init(); err != nil {
+		t.Fatalf("secret.Init: %v", err)
+	}
+
+	received := make(map[string]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received[r.URL.Path] = string(body)
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	summary := &Summary{
+		RunID:   "run-1",
+		Trigger: "WORKER",
+		Status:  "PARTIAL_FAILURE",
+		Total:   2,
+		Success: 1,
+		Fail:    1,
+		Failing: []*Change{{
+			Username: "alice.stg", DomainType: "stg", Owner: "U000TEST",
+			State: StateFailing, HTTPCode: 401, ErrorCode: "WRONG_PASSWORD",
+		}},
+		Recovered: []*Change{{
+			Username: "bob.stg", DomainType: "stg", Owner: "qa@example.com", State: StateRecovered,
+		}},
+	}
+	hooks := []*config.Webhook{
+		{Kind: config.WebhookSlack, URL: srv.URL + "/slack"},
+		{Kind: config.WebhookGoogleChat, URL: srv.URL + "/chat"},
+		{Kind: config.WebhookJSON, URL: srv.URL + "/json"},
+	}
+	if err := Send(hooks, summary); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var slack map[string]string
+	if err := json.Unmarshal([]byte(received["/slack"]), &slack); err != nil {
+		t.Fatalf("slack payload %q: %v", received["/slack"], err)
+	}
+	for _, want := range []string{"stg__alice.stg", "<@U000TEST>", "WRONG_PASSWORD", "Recovered:"} {
+		if !strings.Contains(slack["text"], want) {
+			t.Errorf("slack text %q does not contain %q", slack["text"], want)
+		}
+	}
+	var chat map[string]string
+	if err := json.Unmarshal([]byte(received["/chat"]), &chat); err != nil {
+		t.Fatalf("chat payload %q: %v", received["/chat"], err)
+	}
+	if !strings.Contains(chat["text"], "<users/qa@example.com>") {
+		t.Errorf("chat text %q does not mention the owner", chat["text"])
+	}
+
+	var generic Summary
+	if err := json.Unmarshal([]byte(received["/json"]), &generic); err != nil {
+		t.Fatalf("json payload: %v", err)
+	}
+	if generic.RunID != "run-1" || len(generic.Failing) != 1 || generic.Failing[0].Owner != "U000TEST" {
+		t.Errorf("json payload = %+v, want the summary", generic)
+	}
+
+	err := Send([]*config.Webhook{{Name: "broken", Kind: config.WebhookJSON, URL: srv.URL + "/broken"}}, summary)
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Send to a failing webhook: err = %v, want an error naming it", err)
+	}
+}
+
+func TestRenderPausedAccounts(t *testing.T) {
+	summary := &Summary{
+		RunID: "run-2", Trigger: "SCHEDULE", Status: "FAILED", Total: 1, Fail: 1,
+		Paused: []*Change{{
+			Username: "qa.stg", DomainType: "stg", Owner: "U000TEST", State: StatePaused,
+			HTTPCode: 401, ErrorCode: "WRONG_PASSWORD", Failures: 3,
+		}},
+	}
+	if !summary.HasChanges() {
+		t.Fatal("a paused account must be notified")
+	}
+	text := render(summary, slackMention)
+	for _, want := range []string{"Paused until re-enabled:", "stg__qa.stg after 3 consecutive failures (401 WRONG_PASSWORD)", "<@U000TEST>"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text %q does not contain %q", text, want)
+		}
+	}
+}
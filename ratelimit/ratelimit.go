@@ -0,0 +1,68 @@
+// Package ratelimit provides a token bucket that spaces out calls to a
+// remote service.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Bucket holds up to burst tokens and refills at rate tokens per second.
+// A nil *Bucket never blocks.
+type Bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	sleep  func(time.Duration)
+}
+
+// NewBucket returns a full bucket. A rate <= 0 disables limiting and returns
+// nil; a burst < 1 is raised to 1.
+func NewBucket(rate float64, burst int) *Bucket {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &Bucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+// Wait blocks until a token is available and takes it.
+func (b *Bucket) Wait() {
+	if b == nil {
+		return
+	}
+	b.sleep(b.reserve())
+}
+
+// reserve takes a token, possibly going into debt, and returns how long the
+// caller must wait for it. Reserving under the lock keeps concurrent waiters
+// in order without holding the lock while sleeping.
+func (b *Bucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
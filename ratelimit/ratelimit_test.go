@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func fakeClock(b *Bucket) *time.Time {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b.last = now
+	b.now = func() time.Time { return now }
+	return &now
+}
+
+func TestBucketReserve(t *testing.T) {
+	b := NewBucket(2, 2)
+	now := fakeClock(b)
+
+	want := []time.Duration{0, 0, 500 * time.Millisecond, time.Second}
+	for i, w := range want {
+		if got := b.reserve(); got != w {
+			t.Fatalf("reserve #%d = %v, want %v", i, got, w)
+		}
+	}
+
+	// After two seconds the debt of two tokens is paid back, and the
+	// bucket does not refill beyond its burst.
+	*now = now.Add(10 * time.Second)
+	for i := 0; i < 2; i++ {
+		if got := b.reserve(); got != 0 {
+			t.Fatalf("reserve after refill #%d = %v, want 0", i, got)
+		}
+	}
+	if got := b.reserve(); got != 500*time.Millisecond {
+		t.Fatalf("reserve beyond burst = %v, want 500ms", got)
+	}
+}
+
+func TestNilBucketNeverBlocks(t *testing.T) {
+	b := NewBucket(0, 5)
+	if b != nil {
+		t.Fatalf("NewBucket(0, 5) = %v, want nil", b)
+	}
+	b.Wait()
+}
@@ -0,0 +1,281 @@
+// Package secret implements envelope encryption for credentials stored by the
+// service. Every value is sealed with its own random data key (AES-256-GCM),
+// and the data key is in turn sealed with the master key.
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	Prefix  = "enc:v1:"
+	keySize = 32
+)
+
+var (
+	ErrNoMasterKey = errors.New("no master key configured (MASTER_KEY or MASTER_KEY_FILE)")
+	ErrUnknownKey  = errors.New("value was sealed with an unknown master key")
+	ErrMalformed   = errors.New("malformed encrypted value")
+	ErrPlaintext   = errors.New("plaintext secret found while INSECURE_DEV is off")
+)
+
+var (
+	defaultKeyring *Keyring
+	allowPlaintext bool
+
+	base64Encoding   = base64.RawStdEncoding
+	dataKeyAAD       = []byte("data-key")
+	errShortCipher   = errors.New("ciphertext too short")
+	errInvalidKeyLen = fmt.Errorf("master key must be %d bytes", keySize)
+)
+
+// Keyring holds the primary master key used for sealing and any previous keys
+// still accepted for opening during a rotation.
+type Keyring struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// NewKeyring builds a keyring whose first key is the primary one.
+func NewKeyring(primary []byte, previous ...[]byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string][]byte)}
+	for i, key := range append([][]byte{primary}, previous...) {
+		if len(key) != keySize {
+			return nil, errInvalidKeyLen
+		}
+		id := keyID(key)
+		if i == 0 {
+			k.primary = id
+		}
+		k.keys[id] = key
+	}
+	return k, nil
+}
+
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// PrimaryKeyID returns the id of the key new values are sealed with.
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primary
+}
+
+// Encrypt seals plaintext with a fresh data key wrapped by the primary key.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+
+	wrapped, err := seal(k.keys[k.primary], dataKey, dataKeyAAD)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(dataKey, []byte(plaintext), []byte(k.primary))
+	if err != nil {
+		return "", err
+	}
+
+	return Prefix + k.primary + ":" + base64Encoding.EncodeToString(wrapped) + ":" + base64Encoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt with any key of the ring.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	id, wrapped, sealed, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+	master, ok := k.keys[id]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+
+	dataKey, err := open(master, wrapped, dataKeyAAD)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataKey, sealed, []byte(id))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether value is plaintext or sealed with a key other
+// than the primary one.
+func (k *Keyring) NeedsRotation(value string) bool {
+	id, _, _, err := parse(value)
+	return err != nil || id != k.primary
+}
+
+func parse(value string) (id string, wrapped, sealed []byte, err error) {
+	if !IsEncrypted(value) {
+		return "", nil, nil, ErrMalformed
+	}
+	parts := strings.Split(strings.TrimPrefix(value, Prefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, ErrMalformed
+	}
+	if wrapped, err = base64Encoding.DecodeString(parts[1]); err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	if sealed, err = base64Encoding.DecodeString(parts[2]); err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	return parts[0], wrapped, sealed, nil
+}
+
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(key, ciphertext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errShortCipher
+	}
+	nonce, body := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, body, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// IsEncrypted reports whether value carries the envelope prefix.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// Init loads the master keys from the environment:
+//   - MASTER_KEY / MASTER_KEY_FILE: base64 encoded 32-byte primary key
+//   - MASTER_KEY_PREVIOUS / MASTER_KEY_PREVIOUS_FILE: comma or newline separated
+//     keys still accepted for decryption while rotating
+//   - INSECURE_DEV=true: tolerate plaintext secrets and a missing master key
+func Init() error {
+	allowPlaintext = os.Getenv("INSECURE_DEV") == "true"
+
+	primary, err := readKeys("MASTER_KEY")
+	if err != nil {
+		return err
+	}
+	if len(primary) == 0 {
+		if allowPlaintext {
+			fmt.Println("[secret] INSECURE_DEV is set and no master key is configured: secrets are kept in plaintext")
+			return nil
+		}
+		return ErrNoMasterKey
+	}
+	if len(primary) > 1 {
+		return errors.New("MASTER_KEY must hold exactly one key")
+	}
+
+	previous, err := readKeys("MASTER_KEY_PREVIOUS")
+	if err != nil {
+		return err
+	}
+	keyring, err := NewKeyring(primary[0], previous...)
+	if err != nil {
+		return err
+	}
+	defaultKeyring = keyring
+	return nil
+}
+
+func readKeys(name string) ([][]byte, error) {
+	raw := os.Getenv(name)
+	if path := os.Getenv(name + "_FILE"); raw == "" && path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read %s_FILE: %w", name, err)
+		}
+		raw = string(content)
+	}
+
+	var keys [][]byte
+	for _, field := range strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' '
+	}) {
+		key, err := base64.StdEncoding.DecodeString(field)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// AllowPlaintext reports whether the insecure dev flag is set.
+func AllowPlaintext() bool {
+	return allowPlaintext
+}
+
+// Default returns the keyring loaded by Init, or nil when none is configured.
+func Default() *Keyring {
+	return defaultKeyring
+}
+
+// CheckStored fails when value is a plaintext secret and plaintext is not allowed.
+func CheckStored(value string) error {
+	if value == "" || IsEncrypted(value) || allowPlaintext {
+		return nil
+	}
+	return ErrPlaintext
+}
+
+// Encrypt seals value with the default keyring. Without a keyring (insecure
+// dev mode only) the value is returned unchanged.
+func Encrypt(value string) (string, error) {
+	if value == "" || IsEncrypted(value) {
+		return value, nil
+	}
+	if defaultKeyring == nil {
+		if allowPlaintext {
+			return value, nil
+		}
+		return "", ErrNoMasterKey
+	}
+	return defaultKeyring.Encrypt(value)
+}
+
+// Decrypt opens value with the default keyring. Plaintext values are only
+// passed through in insecure dev mode.
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		if value == "" || allowPlaintext {
+			return value, nil
+		}
+		return "", ErrPlaintext
+	}
+	if defaultKeyring == nil {
+		return "", ErrNoMasterKey
+	}
+	return defaultKeyring.Decrypt(value)
+}
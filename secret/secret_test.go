@@ -0,0 +1,77 @@
+package secret
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestKeyringRoundTripAndRotation(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, keySize)
+	newKey := bytes.Repeat([]byte{2}, keySize)
+
+	old, err := NewKeyring(oldKey)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	sealed, err := old.Encrypt("s3cret-password")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !IsEncrypted(sealed) || strings.Contains(sealed, "s3cret") {
+		t.Fatalf("Encrypt returned %q, want an opaque envelope", sealed)
+	}
+
+	rotated, err := NewKeyring(newKey, oldKey)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	if !rotated.NeedsRotation(sealed) {
+		t.Errorf("NeedsRotation = false for a value sealed with the previous key")
+	}
+	got, err := rotated.Decrypt(sealed)
+	if err != nil || got != "s3cret-password" {
+		t.Fatalf("Decrypt = %q, %v; want the original password", got, err)
+	}
+
+	resealed, err := rotated.Encrypt(got)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if rotated.NeedsRotation(resealed) {
+		t.Errorf("NeedsRotation = true for a value sealed with the primary key")
+	}
+	if _, err = old.Decrypt(resealed); err != ErrUnknownKey {
+		t.Errorf("Decrypt with retired keyring: err = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestKeyringRejectsTamperedValue(t *testing.T) {
+	k, _ := NewKeyring(bytes.Repeat([]byte{3}, keySize))
+	sealed, _ := k.Encrypt("s3cret-seed")
+
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if tampered == sealed {
+		tampered = sealed[:len(sealed)-2] + "BB"
+	}
+	if _, err := k.Decrypt(tampered); err == nil {
+		t.Fatal("Decrypt accepted a tampered value")
+	}
+}
+
+func TestDecryptRefusesPlaintextOutsideDevMode(t *testing.T) {
+	allowPlaintext = false
+	defer func() { allowPlaintext = false }()
+
+	if _, err := Decrypt("plain-s3cret"); err != ErrPlaintext {
+		t.Errorf("Decrypt(plaintext) err = %v, want %v", err, ErrPlaintext)
+	}
+	if err := CheckStored("plain-s3cret"); err != ErrPlaintext {
+		t.Errorf("CheckStored(plaintext) err = %v, want %v", err, ErrPlaintext)
+	}
+
+	allowPlaintext = true
+	if got, err := Decrypt("plain-s3cret"); err != nil || got != "plain-s3cret" {
+		t.Errorf("Decrypt in dev mode = %q, %v; want passthrough", got, err)
+	}
+}
@@ -19,7 +19,7 @@ package main
 
 import (
 	"context"
-	"io"
+	"encoding/json"
 	"net/http"
 	"os"
 	"testing"
@@ -39,20 +39,22 @@ func TestSystem(t *testing.T) {
 	}
 	ts := oauth2.StaticTokenSource(&tok)
 	client := oauth2.NewClient(ctx, ts)
-	resp, err := client.Get(baseURL)
+	resp, err := client.Get(baseURL + "/runs?limit=1")
 	if err != nil {
 		t.Fatalf("unable to complete request: %v", err)
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("resp.StatusCode = %d, want %d", resp.StatusCode, http.StatusOK)
+	// An empty run history answers 404 NOT_FOUND in the same envelope.
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("resp.StatusCode = %d, want %d or %d", resp.StatusCode, http.StatusOK, http.StatusNotFound)
 	}
-	gotBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		t.Fatalf("unable to read response body: %v", err)
+	var body struct {
+		Status string `json:"status"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("unable to decode response body: %v", err)
 	}
-	wantBody := "Hello World!\n"
-	if string(gotBody) != wantBody {
-		t.Fatalf("resp.Body = %q, want %q", gotBody, wantBody)
+	if body.Status != "OK" && body.Status != "NOT_FOUND" {
+		t.Fatalf("resp.Body.status = %q, want OK or NOT_FOUND", body.Status)
 	}
 }
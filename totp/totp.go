@@ -0,0 +1,64 @@
+// Package totp generates the time-based one-time passwords of RFC 6238 with
+// the parameters authenticator apps use: HMAC-SHA1, 30 second steps and six
+// digits.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// Period is the lifetime of one code.
+	Period = 30 * time.Second
+	// Digits is the length of a code.
+	Digits = 6
+)
+
+var ErrInvalidSeed = errors.New("totp: seed must be non-empty base32")
+
+// DecodeSeed decodes a base32 seed as shown by enrolment screens. Case,
+// spaces, dashes and padding are ignored.
+func DecodeSeed(seed string) ([]byte, error) {
+	cleaned := strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "=", "").Replace(seed))
+	if cleaned == "" {
+		return nil, ErrInvalidSeed
+	}
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(cleaned)
+	if err != nil {
+		return nil, ErrInvalidSeed
+	}
+	return key, nil
+}
+
+// Code returns the code of the base32 seed valid at t.
+func Code(seed string, t time.Time) (string, error) {
+	key, err := DecodeSeed(seed)
+	if err != nil {
+		return "", err
+	}
+	return generate(key, uint64(t.Unix())/uint64(Period/time.Second), Digits), nil
+}
+
+// generate is the HOTP function of RFC 4226 for the given counter.
+func generate(key []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
@@ -0,0 +1,51 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// The SHA1 test vectors of RFC 6238, appendix B. The key is the ASCII string
+// "12345678901234567890", GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ in base32.
+func TestRFC6238Vectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	for _, tc := range []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	} {
+		if got := generate(key, uint64(tc.unix)/30, 8); got != tc.want {
+			t.Errorf("T=%d: got %s, want %s", tc.unix, got, tc.want)
+		}
+	}
+}
+
+func TestCode(t *testing.T) {
+	seed := "gezd gnbv gy3t qojq gezd gnbv gy3t qojq"
+	code, err := Code(seed, time.Unix(59, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "287082" {
+		t.Errorf("got %s, want 287082", code)
+	}
+
+	next, _ := Code(seed, time.Unix(60, 0))
+	if next == code {
+		t.Errorf("code did not change with the time step")
+	}
+}
+
+func TestDecodeSeedRejectsInvalid(t *testing.T) {
+	for _, seed := range []string{"", "  ", "not base32!", "1111"} {
+		if _, err := DecodeSeed(seed); err != ErrInvalidSeed {
+			t.Errorf("%q: got %v, want ErrInvalidSeed", seed, err)
+		}
+	}
+}